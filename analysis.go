@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jadekler/lean/internal"
+)
+
+// analysisWorkers is how many vertices or edges are sized or analyzed at once.
+const analysisWorkers = 20
+
+// Kinds of analysisEvent.
+const (
+	analysisProgressKind = "progress"
+	analysisSizeKind     = "size"
+	analysisUsagesKind   = "usages"
+	analysisDoneKind     = "done"
+)
+
+// analysisProgress is how far analyzeGraph has got.
+type analysisProgress struct {
+	SizedVertices, NumVertices int
+	AnalyzedEdges, NumEdges    int
+	Done                       bool
+
+	// Failed is how many of the sized vertices and analyzed edges failed.
+	Failed int
+
+	// Missing is how many modules weren't sized because they're missing,
+	// and lean is offline.
+	Missing int
+}
+
+// analysisEvent is a vertex's size or an edge's usages found by analyzeGraph,
+// or a report of its progress.
+type analysisEvent struct {
+	Kind string
+
+	// Label and SizeBytes are set for analysisSizeKind events.
+	Label     string `json:",omitempty"`
+	SizeBytes int64  `json:",omitempty"`
+
+	// From, To and NumUsages are set for analysisUsagesKind events.
+	From      string `json:",omitempty"`
+	To        string `json:",omitempty"`
+	NumUsages int    `json:",omitempty"`
+
+	// Status and Error are set if sizing the vertex or analyzing the edge
+	// failed, in which case its size or usages are -1. See Vertex.
+	Status string `json:",omitempty"`
+	Error  string `json:",omitempty"`
+
+	// Progress is how far the analysis had got, including this event.
+	Progress analysisProgress
+}
+
+// analyzeGraph sizes every vertex and analyzes every edge of g whose analysis
+// is pending, calling found with each result as it comes in. found isn't
+// called concurrently. A vertex or edge that fails doesn't stop the rest: its
+// event says why it failed.
+//
+// g itself isn't changed: found decides where results go. See
+// graph.applyAnalysis.
+func analyzeGraph(g *graph, sizer ReplaceableModuleSizer, parser ReplaceableASTParser, found func(analysisEvent)) {
+	g.mu.Lock()
+	var labels []string
+	for l, v := range g.vertices {
+		if v.AnalysisStatus == analysisPending {
+			labels = append(labels, l)
+		}
+	}
+	var edges [][2]string
+	for from, tos := range *g.edges {
+		for to, e := range tos {
+			if e.AnalysisStatus == analysisPending {
+				edges = append(edges, [2]string{from, to})
+			}
+		}
+	}
+	g.mu.Unlock()
+	sort.Strings(labels)
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+
+	// Sizing and analyzing each take O(seconds), so they're done by a pool of
+	// workers, and their results are collected here.
+	jobs := make(chan func() analysisEvent)
+	results := make(chan analysisEvent)
+
+	var wg sync.WaitGroup
+	for i := 0; i < analysisWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				results <- job()
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, l := range labels {
+			l := l
+			jobs <- func() analysisEvent {
+				ev := analysisEvent{Kind: analysisSizeKind, Label: l}
+				sizeBytes, err := sizer.ModuleSize(l)
+				if err != nil {
+					ev.SizeBytes = -1
+					ev.Status, ev.Error = analysisErrorStatus(err), err.Error()
+					return ev
+				}
+				ev.SizeBytes = sizeBytes
+				return ev
+			}
+		}
+		for _, e := range edges {
+			e := e
+			jobs <- func() analysisEvent {
+				ev := analysisEvent{Kind: analysisUsagesKind, From: e[0], To: e[1]}
+				numUsages, err := parser.ModuleUsagesForModule(e[0], e[1])
+				if err != nil {
+					ev.NumUsages = -1
+					ev.Status, ev.Error = analysisErrorStatus(err), err.Error()
+					return ev
+				}
+				ev.NumUsages = numUsages
+				return ev
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	p := analysisProgress{NumVertices: len(labels), NumEdges: len(edges)}
+	for ev := range results {
+		if ev.Kind == analysisSizeKind {
+			p.SizedVertices++
+		} else {
+			p.AnalyzedEdges++
+		}
+		switch {
+		case ev.Status == analysisFailed:
+			p.Failed++
+		case ev.Status == analysisMissing && ev.Kind == analysisSizeKind:
+			p.Missing++
+		}
+		ev.Progress = p
+		found(ev)
+	}
+}
+
+// analysisErrorStatus returns the analysis status of a vertex or edge whose
+// analysis failed with err.
+func analysisErrorStatus(err error) string {
+	if errors.Is(err, internal.ErrOffline) {
+		return analysisMissing
+	}
+	return analysisFailed
+}
+
+// analysisFailures describes every vertex and edge of g whose analysis
+// failed, sorted. Missing modules aren't failures: see missingModules.
+func (g *graph) analysisFailures() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var out []string
+	for l, v := range g.vertices {
+		if v.AnalysisStatus == analysisFailed {
+			out = append(out, fmt.Sprintf("couldn't size %s: %s", l, v.AnalysisError))
+		}
+	}
+	for _, tos := range *g.edges {
+		for _, e := range tos {
+			if e.AnalysisStatus == analysisFailed {
+				out = append(out, fmt.Sprintf("couldn't analyze %s -> %s: %s", e.From.Label, e.To.Label, e.AnalysisError))
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// missingModules returns the vertices of g that weren't sized because they're
+// missing, and lean is offline, sorted.
+func (g *graph) missingModules() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var out []string
+	for l, v := range g.vertices {
+		if v.AnalysisStatus == analysisMissing {
+			out = append(out, l)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// logAnalysisSummary logs every failure in g's analysis, followed by the
+// modules that are missing.
+func logAnalysisSummary(g *graph) {
+	for _, f := range g.analysisFailures() {
+		log.Println(f)
+	}
+	if missing := g.missingModules(); len(missing) > 0 {
+		log.Printf("%d modules are missing, and weren't downloaded in offline mode. Their sizes are unknown:\n\t%s", len(missing), strings.Join(missing, "\n\t"))
+	}
+}
+
+// applyAnalysis copies a result of analyzeGraph into g, if g has the vertex or
+// edge that it's for.
+func (g *graph) applyAnalysis(ev analysisEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch ev.Kind {
+	case analysisSizeKind:
+		v, ok := g.vertices[ev.Label]
+		if !ok {
+			return
+		}
+		v.SizeBytes = ev.SizeBytes
+		v.AnalysisStatus = ev.Status
+		v.AnalysisError = ev.Error
+		// Retained and shared sizes are recalculated along with the dominator
+		// tree, the next time that it's needed.
+		g.dom = nil
+	case analysisUsagesKind:
+		// Usages don't change the dominator tree or any sizes, so it's kept.
+		if e, ok := (*g.edges)[ev.From][ev.To]; ok {
+			e.applyAnalysis(ev)
+		}
+	}
+}
+
+// applyAnalysis copies a result of analyzeGraph into the cut edges in c.
+// Their vertices belong to a graph, so only their usages need copying.
+func (c *cart) applyAnalysis(ev analysisEvent) {
+	if ev.Kind != analysisUsagesKind {
+		return
+	}
+	if e, ok := c.Edges[ev.From][ev.To]; ok {
+		e.applyAnalysis(ev)
+	}
+	for _, inEdges := range c.Vertices {
+		if e, ok := inEdges[ev.From][ev.To]; ok {
+			e.applyAnalysis(ev)
+		}
+	}
+}
+
+// applyAnalysis copies the usages that ev found into e.
+func (e *edge) applyAnalysis(ev analysisEvent) {
+	e.NumUsages = ev.NumUsages
+	e.AnalysisStatus = ev.Status
+	e.AnalysisError = ev.Error
+}
+
+// analysisFeed passes the events of an analysis running in the background to
+// whoever is watching it, such as the browsers connected to /events.
+type analysisFeed struct {
+	mu          sync.Mutex
+	progress    analysisProgress
+	subscribers map[chan analysisEvent]struct{}
+}
+
+func newAnalysisFeed() *analysisFeed {
+	return &analysisFeed{subscribers: make(map[chan analysisEvent]struct{})}
+}
+
+// subscribe returns a channel of the events published from now on, which is
+// closed once the analysis is done, and how far the analysis has got. If it's
+// already done, the channel is nil.
+func (f *analysisFeed) subscribe() (chan analysisEvent, analysisProgress) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.progress.Done {
+		return nil, f.progress
+	}
+	// Subscribers that fall this far behind miss events, rather than hold up
+	// the analysis.
+	ch := make(chan analysisEvent, 1000)
+	f.subscribers[ch] = struct{}{}
+	return ch, f.progress
+}
+
+// unsubscribe stops sending events to ch.
+func (f *analysisFeed) unsubscribe(ch chan analysisEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subscribers[ch]; ok {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends ev to every subscriber.
+func (f *analysisFeed) publish(ev analysisEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.progress = ev.Progress
+	for ch := range f.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// finish records that the analysis is done, and closes every subscriber's
+// channel.
+func (f *analysisFeed) finish() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.progress.Done = true
+	for ch := range f.subscribers {
+		close(ch)
+	}
+	f.subscribers = make(map[chan analysisEvent]struct{})
+}
+
+// doneEvent is the last event of the analysis. Subscribers send it themselves
+// once their channel is closed, so that it's never missed.
+func (f *analysisFeed) doneEvent() analysisEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return analysisEvent{Kind: analysisDoneKind, Progress: f.progress}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jadekler/lean/internal"
+)
+
+// Implements ReplaceableModuleSizer, failing to size the given modules, and
+// sizing the rest at 1 byte.
+type erroringModuleSizer []string
+
+func (s erroringModuleSizer) ModuleSize(module string) (int64, error) {
+	for _, m := range s {
+		if m == module {
+			return -1, fmt.Errorf("no such module %s", module)
+		}
+	}
+	return 1, nil
+}
+
+// Implements ReplaceableASTParser, failing to analyze the given "from to"
+// edges, and giving the rest 1 usage.
+type erroringASTParser []string
+
+func (p erroringASTParser) ModuleUsagesForModule(from, to string) (int, error) {
+	for _, e := range p {
+		if e == from+" "+to {
+			return -1, fmt.Errorf("can't parse %s", from)
+		}
+	}
+	return 1, nil
+}
+
+func TestAnalyzeGraph(t *testing.T) {
+	g, err := parseGraph(bytes.NewBufferString("a b\na c\nb c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for l, v := range g.vertices {
+		if v.SizeBytes != -1 {
+			t.Errorf("got size %d for %s before analysis, want -1", v.SizeBytes, l)
+		}
+	}
+
+	// Analysis results are copied into scenarios, which have their own
+	// vertices and edges, and into what's been cut.
+	s := newScenario(g)
+	if err := s.history.do(operation{Kind: cutEdge, From: "a", To: "b"}, s.graph, s.cart); err != nil {
+		t.Fatal(err)
+	}
+
+	var last analysisProgress
+	analyzeGraph(g, mapModuleSizer{"a": 1, "b": 10, "c": 100}, mapASTParser{"a b": 2, "a c": 3, "b c": 4}, func(ev analysisEvent) {
+		g.applyAnalysis(ev)
+		s.graph.applyAnalysis(ev)
+		s.cart.applyAnalysis(ev)
+		last = ev.Progress
+	})
+	if want := (analysisProgress{SizedVertices: 3, NumVertices: 3, AnalyzedEdges: 3, NumEdges: 3}); last != want {
+		t.Errorf("got progress %+v, want %+v", last, want)
+	}
+
+	want, err := newGraphWith(bytes.NewBufferString("a b\na c\nb c"), mapModuleSizer{"a": 1, "b": 10, "c": 100}, mapASTParser{"a b": 2, "a c": 3, "b c": 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(g.connected(g.root), want.connected(want.root)); diff != "" {
+		t.Errorf("got different graph (extraneous -, missing +):\n%s", diff)
+	}
+	if got := s.graph.vertices["c"].SizeBytes; got != 100 {
+		t.Errorf("got size %d for c in the scenario, want 100", got)
+	}
+	if got := s.cart.Edges["a"]["b"].NumUsages; got != 2 {
+		t.Errorf("got %d usages for the cut edge a -> b, want 2", got)
+	}
+
+	if got := s.cart.Edges["a"]["b"].AnalysisStatus; got != "" {
+		t.Errorf("got status %q for the cut edge a -> b, want none", got)
+	}
+}
+
+func TestAnalyzeGraphFailures(t *testing.T) {
+	g, err := parseGraph(bytes.NewBufferString("a b\na c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := g.vertices["b"].AnalysisStatus; got != analysisPending {
+		t.Errorf("got status %q for b before analysis, want %q", got, analysisPending)
+	}
+
+	// A failure is recorded on its vertex or edge, and doesn't stop the rest.
+	var last analysisProgress
+	analyzeGraph(g, erroringModuleSizer{"b"}, erroringASTParser{"a c"}, func(ev analysisEvent) {
+		g.applyAnalysis(ev)
+		last = ev.Progress
+	})
+	if want := (analysisProgress{SizedVertices: 3, NumVertices: 3, AnalyzedEdges: 2, NumEdges: 2, Failed: 2}); last != want {
+		t.Errorf("got progress %+v, want %+v", last, want)
+	}
+
+	type status struct {
+		Size   int64
+		Status string
+		Error  string
+	}
+	gotVertices := map[string]status{}
+	for l, v := range g.vertices {
+		gotVertices[l] = status{v.SizeBytes, v.AnalysisStatus, v.AnalysisError}
+	}
+	wantVertices := map[string]status{
+		"a": {1, "", ""},
+		"b": {-1, analysisFailed, "no such module b"},
+		"c": {1, "", ""},
+	}
+	if diff := cmp.Diff(wantVertices, gotVertices); diff != "" {
+		t.Errorf("got different vertices (-want +got):\n%s", diff)
+	}
+
+	gotEdges := map[string]status{}
+	for _, tos := range *g.edges {
+		for _, e := range tos {
+			gotEdges[e.From.Label+" "+e.To.Label] = status{int64(e.NumUsages), e.AnalysisStatus, e.AnalysisError}
+		}
+	}
+	wantEdges := map[string]status{
+		"a b": {1, "", ""},
+		"a c": {-1, analysisFailed, "can't parse a"},
+	}
+	if diff := cmp.Diff(wantEdges, gotEdges); diff != "" {
+		t.Errorf("got different edges (-want +got):\n%s", diff)
+	}
+	wantFailures := []string{
+		"couldn't analyze a -> c: can't parse a",
+		"couldn't size b: no such module b",
+	}
+	if diff := cmp.Diff(wantFailures, g.analysisFailures()); diff != "" {
+		t.Errorf("got different failures (-want +got):\n%s", diff)
+	}
+}
+
+func TestAnalysisFeed(t *testing.T) {
+	f := newAnalysisFeed()
+	ch, progress := f.subscribe()
+	if progress.Done {
+		t.Fatal("got a done feed before finishing")
+	}
+
+	ev := analysisEvent{Kind: analysisSizeKind, Label: "a", SizeBytes: 1, Progress: analysisProgress{SizedVertices: 1, NumVertices: 1}}
+	f.publish(ev)
+	if got := <-ch; got != ev {
+		t.Errorf("got event %+v, want %+v", got, ev)
+	}
+
+	f.finish()
+	if _, ok := <-ch; ok {
+		t.Error("got an open channel after finishing")
+	}
+	want := analysisEvent{Kind: analysisDoneKind, Progress: analysisProgress{SizedVertices: 1, NumVertices: 1, Done: true}}
+	if got := f.doneEvent(); got != want {
+		t.Errorf("got done event %+v, want %+v", got, want)
+	}
+	f.unsubscribe(ch)
+
+	if ch, progress := f.subscribe(); ch != nil || !progress.Done {
+		t.Errorf("got %v, %+v subscribing after finishing, want a nil channel and done", ch, progress)
+	}
+}
+
+// Implements ReplaceableModuleSizer, as if every module but the given ones
+// were missing and lean were offline.
+type offlineModuleSizer []string
+
+func (s offlineModuleSizer) ModuleSize(module string) (int64, error) {
+	for _, m := range s {
+		if m == module {
+			return 1, nil
+		}
+	}
+	return -1, &internal.ModuleNotFoundError{Module: module, Err: internal.ErrOffline}
+}
+
+func TestAnalyzeGraphMissing(t *testing.T) {
+	g, err := parseGraph(bytes.NewBufferString("a b\na c\nb c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last analysisProgress
+	analyzeGraph(g, offlineModuleSizer{"a"}, &testASTParser{}, func(ev analysisEvent) {
+		g.applyAnalysis(ev)
+		last = ev.Progress
+	})
+	if want := (analysisProgress{SizedVertices: 3, NumVertices: 3, AnalyzedEdges: 3, NumEdges: 3, Missing: 2}); last != want {
+		t.Errorf("got progress %+v, want %+v", last, want)
+	}
+	if diff := cmp.Diff([]string{"b", "c"}, g.missingModules()); diff != "" {
+		t.Errorf("got different missing modules (-want +got):\n%s", diff)
+	}
+	if got := g.analysisFailures(); len(got) != 0 {
+		t.Errorf("got failures %v, want none", got)
+	}
+	if got, want := g.vertices["b"].AnalysisError, "could not find module b: not downloaded in offline mode"; got != want {
+		t.Errorf("got error %q for b, want %q", got, want)
+	}
+}
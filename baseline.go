@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// baseline is a snapshot of what's reachable in a graph, against which later
+// graphs are checked for new modules and edges.
+type baseline struct {
+	// Vertices are the labels of the vertices, sorted.
+	Vertices []string
+
+	// Edges are the edges between them, sorted.
+	Edges []baselineEdge
+}
+
+// baselineEdge is an edge in a baseline.
+type baselineEdge struct {
+	From, To string
+}
+
+// baseline snapshots what's reachable from g's root.
+func (g *graph) baseline() *baseline {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b := &baseline{Vertices: []string{}, Edges: []baselineEdge{}}
+	for from := range g.reachableLocked(nil) {
+		b.Vertices = append(b.Vertices, from)
+		for to := range (*g.edges)[from] {
+			b.Edges = append(b.Edges, baselineEdge{From: from, To: to})
+		}
+	}
+	sort.Strings(b.Vertices)
+	sort.Slice(b.Edges, func(i, j int) bool {
+		if b.Edges[i].From != b.Edges[j].From {
+			return b.Edges[i].From < b.Edges[j].From
+		}
+		return b.Edges[i].To < b.Edges[j].To
+	})
+	return b
+}
+
+// writeBaseline writes b to a JSON file.
+func writeBaseline(path string, b *baseline) error {
+	out, err := json.MarshalIndent(b, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
+
+// readBaseline reads a baseline from a JSON file written by writeBaseline.
+func readBaseline(path string) (*baseline, error) {
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &baseline{}
+	if err := json.Unmarshal(in, b); err != nil {
+		return nil, fmt.Errorf("error decoding baseline %s: %v", path, err)
+	}
+	return b, nil
+}
+
+// checkBaseline checks what's reachable from g's root against b, returning a
+// failed result for each vertex that isn't in b, and each edge that isn't in b
+// between vertices that are. Each result's detail is the shortest path by
+// which the root reaches the new vertex or edge.
+func (g *graph) checkBaseline(b *baseline) ([]checkResult, error) {
+	oldVertices := make(map[string]struct{})
+	for _, v := range b.Vertices {
+		oldVertices[v] = struct{}{}
+	}
+	oldEdges := make(map[baselineEdge]struct{})
+	for _, e := range b.Edges {
+		oldEdges[e] = struct{}{}
+	}
+
+	current := g.baseline()
+	var out []checkResult
+	for _, v := range current.Vertices {
+		if _, ok := oldVertices[v]; ok {
+			continue
+		}
+		path, err := g.pathTo(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, checkResult{Rule: fmt.Sprintf("new module %s", v), Detail: "via " + strings.Join(path, " -> ")})
+	}
+	for _, e := range current.Edges {
+		// The edges to a new vertex are reported along with it.
+		if _, ok := oldVertices[e.To]; !ok {
+			continue
+		}
+		if _, ok := oldEdges[e]; ok {
+			continue
+		}
+		path, err := g.pathTo(e.From)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, checkResult{Rule: fmt.Sprintf("new edge %s -> %s", e.From, e.To), Detail: "via " + strings.Join(append(path, e.To), " -> ")})
+	}
+	return out, nil
+}
+
+// pathTo returns the labels along the shortest path from g's root to the
+// vertex labelled label, including both ends.
+func (g *graph) pathTo(label string) ([]string, error) {
+	ex, err := g.why(label, 1)
+	if err != nil {
+		return nil, err
+	}
+	out := []string{g.root}
+	for _, e := range ex.Shortest {
+		out = append(out, e.To.Label)
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckBaseline(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	before, err := newGraph(bytes.NewBufferString("a b@v1\nb@v1 c@v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "lean-baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baseline.json")
+	if err := writeBaseline(path, before.baseline()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := readBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBaseline := &baseline{
+		Vertices: []string{"a", "b@v1", "c@v1"},
+		Edges:    []baselineEdge{{From: "a", To: "b@v1"}, {From: "b@v1", To: "c@v1"}},
+	}
+	if diff := cmp.Diff(b, wantBaseline); diff != "" {
+		t.Fatalf("got different baseline (extraneous -, missing +):\n%s", diff)
+	}
+
+	results, err := before.checkBaseline(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("got %v, want nothing new", results)
+	}
+
+	// a now requires c directly, and c brings in d and e.
+	after, err := newGraph(bytes.NewBufferString("a b@v1\na c@v1\nb@v1 c@v1\nc@v1 d@v1\nd@v1 e@v1\nc@v1 e@v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = after.checkBaseline(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []checkResult{
+		{Rule: "new module d@v1", Detail: "via a -> c@v1 -> d@v1"},
+		{Rule: "new module e@v1", Detail: "via a -> c@v1 -> e@v1"},
+		{Rule: "new edge a -> c@v1", Detail: "via a -> c@v1"},
+	}
+	if diff := cmp.Diff(results, want); diff != "" {
+		t.Errorf("got different results (extraneous -, missing +):\n%s", diff)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+// cart is the shopping cart: the cuts that have been made to userGraph.
+type cart struct {
+	// Edges are the edges that were cut one at a time.
+	Edges edgeMap
+
+	// Vertices are the vertices that were cut entirely, each with the edges
+	// into it that were cut along with it.
+	Vertices map[string]edgeMap
+}
+
+func newCart() *cart {
+	return &cart{Edges: edgeMap{}, Vertices: make(map[string]edgeMap)}
+}
+
+// addEdge records that e was cut.
+func (c *cart) addEdge(e *edge) {
+	if _, ok := c.Edges[e.From.Label]; !ok {
+		c.Edges[e.From.Label] = make(map[string]*edge)
+	}
+	c.Edges[e.From.Label][e.To.Label] = e
+}
+
+// removeEdge forgets that the edge from-to was cut.
+func (c *cart) removeEdge(from, to string) {
+	if _, ok := c.Edges[from]; !ok {
+		return
+	}
+	delete(c.Edges[from], to)
+	if len(c.Edges[from]) == 0 {
+		delete(c.Edges, from)
+	}
+}
+
+// addVertex records that the vertex was cut, along with the given edges into
+// it.
+func (c *cart) addVertex(label string, inEdges edgeMap) {
+	c.Vertices[label] = inEdges
+}
+
+// removeVertex forgets that the vertex was cut, and returns the edges that
+// were cut along with it.
+func (c *cart) removeVertex(label string) (edgeMap, bool) {
+	inEdges, ok := c.Vertices[label]
+	delete(c.Vertices, label)
+	return inEdges, ok
+}
+
+// removeVertexEdge forgets that the edge from-to was cut along with the vertex
+// to. If it was the last such edge, the vertex is no longer cut.
+func (c *cart) removeVertexEdge(from, to string) {
+	inEdges, ok := c.Vertices[to]
+	if !ok {
+		return
+	}
+	delete(inEdges[from], to)
+	if len(inEdges[from]) == 0 {
+		delete(inEdges, from)
+	}
+	if len(inEdges) == 0 {
+		delete(c.Vertices, to)
+	}
+}
+
+// cutEdges returns every edge in the cart: those cut one at a time, and those
+// cut along with a vertex.
+func (c *cart) cutEdges() edgeMap {
+	out := edgeMap{}
+	for _, tos := range c.Edges {
+		for _, e := range tos {
+			out.setCopy(e.From, e.To, e)
+		}
+	}
+	for _, inEdges := range c.Vertices {
+		for _, tos := range inEdges {
+			for _, e := range tos {
+				out.setCopy(e.From, e.To, e)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// commands are the subcommands of lean. Each is given the arguments following
+// its name.
+var commands = map[string]func(args []string) error{
+	"recommend": recommendCommand,
+	"suggest":   suggestCommand,
+	"why":       whyCommand,
+	"diff":      diffCommand,
+	"history":   historyCommand,
+	"report":    reportCommand,
+	"check":     checkCommand,
+	"export":    exportCommand,
+}
+
+// recommendCommand prints the cheapest set of edges to cut to remove a module,
+// and what cutting them prunes.
+func recommendCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: go mod graph | lean recommend <module>")
+	}
+	if err := loadGraph(); err != nil {
+		return err
+	}
+
+	rec, err := userGraph.recommendCut(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("To remove %s, cut:\n", rec.Target)
+	for _, e := range sortedEdges(rec.Cut) {
+		fmt.Printf("\t%s -> %s (%s usages)\n", e.From.Label, e.To.Label, formatUsages(e.NumUsages))
+	}
+	fmt.Printf("This prunes %d edges, and %d modules totalling %d bytes:\n", rec.Impact.NumEdges, rec.Impact.NumVertices, rec.Impact.SizeBytes)
+	for _, v := range rec.Vertices {
+		fmt.Printf("\t%s\n", v)
+	}
+	return nil
+}
+
+// suggestCommand prints the top cuts across the whole graph, ranked by how much
+// they prune against how much the cut edge is used.
+func suggestCommand(args []string) error {
+	fs := flag.NewFlagSet("suggest", flag.ExitOnError)
+	n := fs.Int("n", 10, "number of suggestions to print, or 0 for all")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("usage: go mod graph | lean suggest [-n N]")
+	}
+	if err := loadGraph(); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "EDGE\tUSAGES\tBYTES FREED\tMODULES FREED\tSCORE")
+	for _, s := range userGraph.suggestCuts(*n) {
+		fmt.Fprintf(tw, "%s -> %s\t%s\t%d\t%d\t%s\n", s.Edge.From.Label, s.Edge.To.Label, formatUsages(s.Edge.NumUsages), s.Impact.SizeBytes, s.Impact.NumVertices, formatScore(s.Edge.NumUsages, s.Score))
+	}
+	return tw.Flush()
+}
+
+// whyCommand prints the paths by which the root depends on a module.
+func whyCommand(args []string) error {
+	fs := flag.NewFlagSet("why", flag.ExitOnError)
+	maxPaths := fs.Int("max", defaultMaxPaths, "maximum number of paths to print, or 0 for all")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: go mod graph | lean why [-max N] <module>")
+	}
+	if err := loadGraph(); err != nil {
+		return err
+	}
+
+	ex, err := userGraph.why(fs.Arg(0), *maxPaths)
+	if err != nil {
+		return err
+	}
+
+	printPath := func(path []*edge) {
+		fmt.Printf("\t%s\n", userGraph.root)
+		for _, e := range path {
+			fmt.Printf("\t  -> %s (%s usages)\n", e.To.Label, formatUsages(e.NumUsages))
+		}
+	}
+	fmt.Printf("Shortest path to %s:\n", ex.Module)
+	printPath(ex.Shortest)
+	fmt.Printf("\nAll %d paths to %s:\n", len(ex.Paths), ex.Module)
+	for i, p := range ex.Paths {
+		if i > 0 {
+			fmt.Println()
+		}
+		printPath(p)
+	}
+	if ex.Truncated {
+		fmt.Printf("\n(stopped after %d paths; use -max to see more)\n", len(ex.Paths))
+	}
+	return nil
+}
+
+// reportCommand prints every edge, and what cutting it alone would prune, in
+// a format that scripts can consume. With -html, it instead writes a page that
+// shows the graph, and previews cuts, without a server.
+func reportCommand(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("format", reportText, "output format: text, json or csv")
+	htmlPath := fs.String("html", "", "write a self-contained, read-only HTML page of the graph to this file instead")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("usage: go mod graph | lean report [-format text|json|csv] [-html out.html]")
+	}
+	// Check the format before the graph is built, which is slow.
+	if err := writeReport(ioutil.Discard, nil, *format); err != nil {
+		return err
+	}
+	if err := loadGraph(); err != nil {
+		return err
+	}
+
+	if *htmlPath != "" {
+		mu.Lock()
+		s, err := buildSnapshot()
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(*htmlPath)
+		if err != nil {
+			return err
+		}
+		if err := writeHTMLReport(f, s); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return writeReport(os.Stdout, userGraph.report(), *format)
+}
+
+// exportCommand prints the graph in a format that other graph tools can read.
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", exportDOT, "output format: dot, graphml, mermaid or json")
+	cuts := fs.Bool("cuts", false, "include the edges cut in the session loaded with -load, marked as cut")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("usage: go mod graph | lean export [-format dot|graphml|mermaid|json] [-cuts]")
+	}
+	if _, ok := exportContentTypes[*format]; !ok {
+		return writeExport(ioutil.Discard, nil, *format)
+	}
+	if err := loadGraph(); err != nil {
+		return err
+	}
+
+	var cutEdges edgeMap
+	if *cuts {
+		cutEdges = shoppingCart.cutEdges()
+	}
+	eg, err := userGraph.export(cutEdges)
+	if err != nil {
+		return err
+	}
+	return writeExport(os.Stdout, eg, *format)
+}
+
+// checkCommand checks the graph against a policy, or against a baseline for
+// modules and edges that are new since it was written, printing whether each
+// check passed. It fails if any didn't. It can also write the baseline.
+func checkCommand(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	policyPath := fs.String("policy", ".lean-policy.json", "JSON file of rules that the graph must follow; checked if given or if it exists")
+	checkNew := fs.Bool("baseline", false, "check for modules and edges that aren't in the baseline file")
+	baselinePath := fs.String("baseline-file", ".lean-baseline.json", "baseline file to check against or write")
+	updateBaseline := fs.Bool("update-baseline", false, "write the graph to the baseline file instead of checking it")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("usage: go mod graph | lean check [-policy .lean-policy.json] [-baseline] [-baseline-file .lean-baseline.json] [-update-baseline]")
+	}
+
+	if *updateBaseline {
+		if err := loadGraph(); err != nil {
+			return err
+		}
+		if err := writeBaseline(*baselinePath, userGraph.baseline()); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote the baseline to %s\n", *baselinePath)
+		return nil
+	}
+
+	// Read the policy and baseline before the graph is built, which is slow.
+	// Checking the baseline alone doesn't need a policy, unless one was asked
+	// for.
+	var p *policy
+	policySet := false
+	fs.Visit(func(f *flag.Flag) { policySet = policySet || f.Name == "policy" })
+	_, statErr := os.Stat(*policyPath)
+	if !*checkNew || policySet || statErr == nil {
+		var err error
+		if p, err = readPolicy(*policyPath); err != nil {
+			return err
+		}
+	}
+	var b *baseline
+	if *checkNew {
+		var err error
+		if b, err = readBaseline(*baselinePath); err != nil {
+			return err
+		}
+	}
+	if err := loadGraph(); err != nil {
+		return err
+	}
+
+	var results []checkResult
+	if p != nil {
+		results = append(results, userGraph.checkPolicy(p)...)
+	}
+	if b != nil {
+		newResults, err := userGraph.checkBaseline(b)
+		if err != nil {
+			return err
+		}
+		if len(newResults) == 0 {
+			newResults = []checkResult{{Rule: fmt.Sprintf("nothing new since %s", *baselinePath), OK: true}}
+		}
+		results = append(results, newResults...)
+	}
+
+	failed := 0
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, r := range results {
+		status := "ok"
+		if !r.OK {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", status, r.Rule, r.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// diffCommand prints how the graph in one file of `go mod graph` output
+// differs from the graph in another, or serves the second graph with the
+// differences highlighted.
+func diffCommand(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	serveDiff := fs.Bool("serve", false, "serve the after graph, with what changed highlighted, instead of printing the diff")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: lean diff [-serve] before.txt after.txt")
+	}
+
+	var graphs []*graph
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		g, err := newGraph(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		graphs = append(graphs, g)
+	}
+	before, after := graphs[0], graphs[1]
+
+	if *serveDiff {
+		mu.Lock()
+		diffBase = before
+		err := useGraph(after)
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+		serve()
+		return nil
+	}
+
+	d := diffGraphs(before, after)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Modules:\t%d -> %d\t(%+d)\n", d.Before.NumVertices, d.After.NumVertices, d.After.NumVertices-d.Before.NumVertices)
+	fmt.Fprintf(tw, "Edges:\t%d -> %d\t(%+d)\n", d.Before.NumEdges, d.After.NumEdges, d.After.NumEdges-d.Before.NumEdges)
+	fmt.Fprintf(tw, "Bytes:\t%d -> %d\t(%+d)\n", d.Before.SizeBytes, d.After.SizeBytes, d.After.SizeBytes-d.Before.SizeBytes)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	printVertices := func(title string, g *graph, labels []string) {
+		if len(labels) == 0 {
+			return
+		}
+		fmt.Printf("\n%s:\n", title)
+		for _, l := range labels {
+			fmt.Printf("\t%s (%d bytes)\n", l, g.vertices[l].SizeBytes)
+		}
+	}
+	printVertices("Added modules", after, d.AddedVertices)
+	printVertices("Removed modules", before, d.RemovedVertices)
+
+	if len(d.VersionChanges) > 0 {
+		fmt.Printf("\nVersion changes:\n")
+		for _, c := range d.VersionChanges {
+			from, to := c.Before, c.After
+			if from == "" {
+				from = "(none)"
+			}
+			if to == "" {
+				to = "(none)"
+			}
+			fmt.Printf("\t%s %s -> %s\n", c.Path, from, to)
+		}
+	}
+
+	printEdges := func(title string, em edgeMap) {
+		if len(em) == 0 {
+			return
+		}
+		fmt.Printf("\n%s:\n", title)
+		for _, e := range sortedEdges(em) {
+			fmt.Printf("\t%s -> %s\n", e.From.Label, e.To.Label)
+		}
+	}
+	printEdges("Added edges", d.AddedEdges)
+	printEdges("Removed edges", d.RemovedEdges)
+	return nil
+}
+
+// historyCommand prints how the module graph of the module in a directory grew
+// over the commits that changed its go.mod or go.sum, or serves the graph with
+// a chart of it.
+func historyCommand(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	serveHistory := fs.Bool("serve", false, "serve the module's graph, with a chart of its history, instead of printing the history")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: lean history [-serve] [module-dir]")
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+
+	h, err := gitHistory(dir)
+	if err != nil {
+		return err
+	}
+
+	if *serveHistory {
+		moduleDirs = []string{dir}
+		if err := loadGraph(); err != nil {
+			return err
+		}
+		mu.Lock()
+		growth = h
+		mu.Unlock()
+		serve()
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMMIT\tDATE\tMODULES\tEDGES\tBYTES\tSUBJECT")
+	for _, p := range h.Points {
+		if p.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t%s (%s)\n", shortHash(p.Commit), p.Time.Format("2006-01-02"), p.Subject, firstLine(p.Error))
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n", shortHash(p.Commit), p.Time.Format("2006-01-02"), p.NumVertices, p.NumEdges, p.SizeBytes, p.Subject)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nModules, by the commit that introduced them:\n")
+	tw = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, m := range h.Introduced {
+		fmt.Fprintf(tw, "\t%s@%s\t%s\t%s\n", m.Path, m.Version, shortHash(m.Commit), m.Time.Format("2006-01-02"))
+	}
+	return tw.Flush()
+}
+
+// shortHash abbreviates a git commit hash.
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// firstLine returns the first line of s.
+func firstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
+
+// sortedEdges returns the edges in em, sorted by from and then to.
+func sortedEdges(em edgeMap) []*edge {
+	var out []*edge
+	for _, tos := range em {
+		for _, e := range tos {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].From.Label != out[j].From.Label {
+			return out[i].From.Label < out[j].From.Label
+		}
+		return out[i].To.Label < out[j].To.Label
+	})
+	return out
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+)
+
+// graphDiff is what changed between two graphs, such as the graphs before and
+// after a change to a module's requirements. Only what's reachable from each
+// graph's root is compared.
+type graphDiff struct {
+	// Before and After are the totals of each graph: everything that cutting
+	// the whole graph would prune.
+	Before, After cutImpact
+
+	// AddedVertices and RemovedVertices are the labels of the vertices only in
+	// after and only in before, respectively, sorted.
+	AddedVertices, RemovedVertices []string
+
+	// AddedEdges are the edges only in after, and RemovedEdges are the edges
+	// only in before.
+	AddedEdges, RemovedEdges edgeMap
+
+	// VersionChanges are the modules whose selected version changed. See
+	// buildListChanges.
+	VersionChanges []versionChange
+}
+
+// diffGraphs compares before and after.
+func diffGraphs(before, after *graph) *graphDiff {
+	d := &graphDiff{
+		AddedVertices:   []string{},
+		RemovedVertices: []string{},
+		AddedEdges:      edgeMap{},
+		RemovedEdges:    edgeMap{},
+		VersionChanges:  buildListChanges(before.buildList(), after.buildList()),
+	}
+
+	before.mu.Lock()
+	defer before.mu.Unlock()
+	after.mu.Lock()
+	defer after.mu.Unlock()
+
+	beforeReachable := before.reachableLocked(nil)
+	afterReachable := after.reachableLocked(nil)
+	d.Before = before.totalLocked(beforeReachable)
+	d.After = after.totalLocked(afterReachable)
+
+	// onlyIn adds the vertices and edges reachable in a but not in b.
+	onlyIn := func(a, b *graph, aReachable, bReachable map[string]struct{}, vertices *[]string, edges edgeMap) {
+		for from := range aReachable {
+			if _, ok := bReachable[from]; !ok {
+				*vertices = append(*vertices, from)
+			}
+			for to, e := range (*a.edges)[from] {
+				if _, ok := bReachable[from]; ok {
+					if _, ok := (*b.edges)[from][to]; ok {
+						continue
+					}
+				}
+				edges.setCopy(e.From, e.To, e)
+			}
+		}
+		sort.Strings(*vertices)
+	}
+	onlyIn(after, before, afterReachable, beforeReachable, &d.AddedVertices, d.AddedEdges)
+	onlyIn(before, after, beforeReachable, afterReachable, &d.RemovedVertices, d.RemovedEdges)
+	return d
+}
+
+// totalLocked sums up the sizes of the given vertices, and counts them and the
+// edges out of them.
+//
+// g.mu must be held.
+func (g *graph) totalLocked(vertices map[string]struct{}) cutImpact {
+	var out cutImpact
+	for l := range vertices {
+		out.NumVertices++
+		out.NumEdges += len((*g.edges)[l])
+		if s := g.vertices[l].SizeBytes; s > 0 {
+			out.SizeBytes += s
+		}
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffGraphs(t *testing.T) {
+	moduleSizer = mapModuleSizer{"a": 1, "b@v1": 10, "c@v1": 100, "c@v2": 200, "d@v1": 1000, "e@v1": 10000}
+	astParser = &testASTParser{}
+	defer func() { moduleSizer = &testModuleSizer{} }()
+
+	// Bumping b drops its requirement of d, and brings in c@v2 and e.
+	before, err := newGraph(bytes.NewBufferString("a b@v1\na c@v1\nb@v1 d@v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := newGraph(bytes.NewBufferString("a b@v1\na c@v1\nb@v1 c@v2\nc@v2 e@v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := diffGraphs(before, after)
+
+	if diff := cmp.Diff(got.Before, cutImpact{SizeBytes: 1111, NumVertices: 4, NumEdges: 3}); diff != "" {
+		t.Errorf("got different before (extraneous -, missing +):\n%s", diff)
+	}
+	if diff := cmp.Diff(got.After, cutImpact{SizeBytes: 10311, NumVertices: 5, NumEdges: 4}); diff != "" {
+		t.Errorf("got different after (extraneous -, missing +):\n%s", diff)
+	}
+	if diff := cmp.Diff(got.AddedVertices, []string{"c@v2", "e@v1"}); diff != "" {
+		t.Errorf("got different added vertices (extraneous -, missing +):\n%s", diff)
+	}
+	if diff := cmp.Diff(got.RemovedVertices, []string{"d@v1"}); diff != "" {
+		t.Errorf("got different removed vertices (extraneous -, missing +):\n%s", diff)
+	}
+
+	edgeLabels := func(em edgeMap) [][2]string {
+		var out [][2]string
+		for _, e := range sortedEdges(em) {
+			out = append(out, [2]string{e.From.Label, e.To.Label})
+		}
+		return out
+	}
+	if diff := cmp.Diff(edgeLabels(got.AddedEdges), [][2]string{{"b@v1", "c@v2"}, {"c@v2", "e@v1"}}); diff != "" {
+		t.Errorf("got different added edges (extraneous -, missing +):\n%s", diff)
+	}
+	if diff := cmp.Diff(edgeLabels(got.RemovedEdges), [][2]string{{"b@v1", "d@v1"}}); diff != "" {
+		t.Errorf("got different removed edges (extraneous -, missing +):\n%s", diff)
+	}
+
+	wantChanges := []versionChange{
+		{Path: "c", Before: "v1", After: "v2"},
+		{Path: "d", Before: "v1"},
+		{Path: "e", After: "v1"},
+	}
+	if diff := cmp.Diff(got.VersionChanges, wantChanges); diff != "" {
+		t.Errorf("got different version changes (extraneous -, missing +):\n%s", diff)
+	}
+}
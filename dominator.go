@@ -0,0 +1,283 @@
+package main
+
+import (
+	"sort"
+)
+
+// domNode is a node in the edge-split graph: either a vertex of the original
+// graph, or an edge of the original graph turned into a vertex.
+//
+// Splitting every edge (from, to) into from -> e -> to means that cutting an
+// edge is the same as removing the node e, so the question "what disappears if
+// I cut (from, to)" becomes "what does e dominate".
+type domNode struct {
+	vertex *Vertex // Set if this node is a vertex.
+	edge   *edge   // Set if this node is an edge.
+}
+
+// cutImpact describes what would be pruned from a graph if some part of it
+// were cut.
+type cutImpact struct {
+	// SizeBytes is the sum of the sizes of the pruned vertices. Vertices of
+	// unknown size are counted as zero.
+	SizeBytes int64
+
+	// NumVertices is the number of pruned vertices.
+	NumVertices int
+
+	// NumEdges is the number of pruned edges, including the cut edges
+	// themselves.
+	NumEdges int
+}
+
+// dominatorTree is the dominator tree of a graph's edge-split graph, rooted at
+// the graph's root. It is built once per graph mutation, and then answers cut
+// queries for any edge without walking the graph again.
+//
+// See https://www.cs.au.dk/~gerth/advising/thesis/henrik-knakkegaard-christensen.pdf.
+type dominatorTree struct {
+	root        int
+	nodes       []domNode
+	vertexIndex map[string]int
+	edgeIndex   map[string]map[string]int
+
+	// idom is the immediate dominator of each node. It is -1 for the root and
+	// for nodes that are not reachable from the root.
+	idom []int
+
+	// children is the inverse of idom, sorted for determinism.
+	children [][]int
+
+	// impact is the cumulative impact of removing each node: everything in
+	// the node's dominator subtree.
+	impact []cutImpact
+}
+
+// newDominatorTree builds the dominator tree of g's edge-split graph using
+// Lengauer-Tarjan.
+//
+// g.mu must be held.
+func newDominatorTree(g *graph) *dominatorTree {
+	dt := &dominatorTree{
+		root:        -1,
+		vertexIndex: make(map[string]int),
+		edgeIndex:   make(map[string]map[string]int),
+	}
+
+	// Number the nodes. Vertices and edges are visited in sorted order so that
+	// the tree's shape doesn't depend on map iteration order.
+	var labels []string
+	for l := range g.vertices {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	for _, l := range labels {
+		dt.vertexIndex[l] = len(dt.nodes)
+		dt.nodes = append(dt.nodes, domNode{vertex: g.vertices[l]})
+	}
+	succ := make([][]int, len(dt.nodes))
+	for _, from := range labels {
+		tos := (*g.edges)[from]
+		var toLabels []string
+		for to := range tos {
+			toLabels = append(toLabels, to)
+		}
+		sort.Strings(toLabels)
+		for _, to := range toLabels {
+			if _, ok := dt.vertexIndex[to]; !ok {
+				continue
+			}
+			e := len(dt.nodes)
+			if _, ok := dt.edgeIndex[from]; !ok {
+				dt.edgeIndex[from] = make(map[string]int)
+			}
+			dt.edgeIndex[from][to] = e
+			dt.nodes = append(dt.nodes, domNode{edge: tos[to]})
+			succ = append(succ, []int{dt.vertexIndex[to]})
+			succ[dt.vertexIndex[from]] = append(succ[dt.vertexIndex[from]], e)
+		}
+	}
+
+	n := len(dt.nodes)
+	dt.idom = make([]int, n)
+	dt.children = make([][]int, n)
+	dt.impact = make([]cutImpact, n)
+	for i := range dt.idom {
+		dt.idom[i] = -1
+	}
+	root, ok := dt.vertexIndex[g.root]
+	if !ok {
+		return dt
+	}
+	dt.root = root
+
+	pred := make([][]int, n)
+	for v, ws := range succ {
+		for _, w := range ws {
+			pred[w] = append(pred[w], v)
+		}
+	}
+
+	// Step 1: number the nodes in DFS order. semi holds the DFS number until
+	// it is replaced by the semidominator's number.
+	semi := make([]int, n)
+	parent := make([]int, n)
+	ancestor := make([]int, n)
+	label := make([]int, n)
+	for i := 0; i < n; i++ {
+		semi[i] = -1
+		ancestor[i] = -1
+		label[i] = i
+	}
+	var vertex []int // DFS number -> node.
+	type frame struct{ v, next int }
+	stack := []frame{{v: root}}
+	semi[root] = 0
+	vertex = append(vertex, root)
+	parent[root] = -1
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		if f.next == len(succ[f.v]) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		w := succ[f.v][f.next]
+		f.next++
+		if semi[w] != -1 {
+			continue
+		}
+		semi[w] = len(vertex)
+		vertex = append(vertex, w)
+		parent[w] = f.v
+		stack = append(stack, frame{v: w})
+	}
+
+	var compress func(v int)
+	compress = func(v int) {
+		a := ancestor[v]
+		if ancestor[a] == -1 {
+			return
+		}
+		compress(a)
+		if semi[label[a]] < semi[label[v]] {
+			label[v] = label[a]
+		}
+		ancestor[v] = ancestor[a]
+	}
+	eval := func(v int) int {
+		if ancestor[v] == -1 {
+			return v
+		}
+		compress(v)
+		return label[v]
+	}
+
+	// Steps 2 and 3: compute semidominators, and implicitly define immediate
+	// dominators.
+	bucket := make([][]int, n)
+	for i := len(vertex) - 1; i > 0; i-- {
+		w := vertex[i]
+		for _, v := range pred[w] {
+			if semi[v] == -1 {
+				continue // Not reachable from the root.
+			}
+			if u := eval(v); semi[u] < semi[w] {
+				semi[w] = semi[u]
+			}
+		}
+		bucket[vertex[semi[w]]] = append(bucket[vertex[semi[w]]], w)
+		p := parent[w]
+		ancestor[w] = p
+		for _, v := range bucket[p] {
+			if u := eval(v); semi[u] < semi[v] {
+				dt.idom[v] = u
+			} else {
+				dt.idom[v] = p
+			}
+		}
+		bucket[p] = nil
+	}
+
+	// Step 4: explicitly define immediate dominators.
+	for i := 1; i < len(vertex); i++ {
+		w := vertex[i]
+		if dt.idom[w] != vertex[semi[w]] {
+			dt.idom[w] = dt.idom[dt.idom[w]]
+		}
+	}
+	dt.idom[root] = -1
+
+	// Since vertex is in DFS order, a node's dominator always comes before it.
+	// So, walking it backwards accumulates each subtree before its parent.
+	for _, w := range vertex[1:] {
+		dt.children[dt.idom[w]] = append(dt.children[dt.idom[w]], w)
+	}
+	for i := len(vertex) - 1; i >= 0; i-- {
+		w := vertex[i]
+		if v := dt.nodes[w].vertex; v != nil {
+			dt.impact[w].NumVertices++
+			if v.SizeBytes > 0 {
+				dt.impact[w].SizeBytes += v.SizeBytes
+			}
+		} else {
+			dt.impact[w].NumEdges++
+		}
+		if p := dt.idom[w]; p != -1 {
+			dt.impact[p].SizeBytes += dt.impact[w].SizeBytes
+			dt.impact[p].NumVertices += dt.impact[w].NumVertices
+			dt.impact[p].NumEdges += dt.impact[w].NumEdges
+		}
+	}
+	for _, c := range dt.children {
+		sort.Ints(c)
+	}
+
+	return dt
+}
+
+// reachable returns whether node i is reachable from the root.
+func (dt *dominatorTree) reachable(i int) bool {
+	return dt.idom[i] != -1 || i == dt.root
+}
+
+// subtree returns the edges and vertex labels dominated by node i, including
+// node i itself.
+func (dt *dominatorTree) subtree(i int) (edgeMap, []string) {
+	edges := edgeMap{}
+	vertices := []string{}
+	stack := []int{i}
+	for len(stack) > 0 {
+		w := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if v := dt.nodes[w].vertex; v != nil {
+			vertices = append(vertices, v.Label)
+		} else {
+			e := dt.nodes[w].edge
+			if _, ok := edges[e.From.Label]; !ok {
+				edges[e.From.Label] = make(map[string]*edge)
+			}
+			edges[e.From.Label][e.To.Label] = e
+		}
+		stack = append(stack, dt.children[w]...)
+	}
+	sort.Strings(vertices)
+	return edges, vertices
+}
+
+// edgeImpacts returns the impact of cutting each edge that is reachable from
+// the root.
+func (dt *dominatorTree) edgeImpacts() map[string]map[string]cutImpact {
+	out := make(map[string]map[string]cutImpact)
+	for from, tos := range dt.edgeIndex {
+		for to, i := range tos {
+			if !dt.reachable(i) {
+				continue
+			}
+			if _, ok := out[from]; !ok {
+				out[from] = make(map[string]cutImpact)
+			}
+			out[from][to] = dt.impact[i]
+		}
+	}
+	return out
+}
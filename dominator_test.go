@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEdgeImpacts(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	for _, tc := range []struct {
+		desc string
+		in   string
+		want map[string]map[string]cutImpact
+	}{
+		{
+			desc: "chain",
+			in: `a b
+b c`,
+			want: map[string]map[string]cutImpact{
+				"a": {"b": {NumVertices: 2, NumEdges: 2}},
+				"b": {"c": {NumVertices: 1, NumEdges: 1}},
+			},
+		},
+		{
+			desc: "diamond",
+			in: `a b
+a c
+b d
+c d`,
+			want: map[string]map[string]cutImpact{
+				"a": {"b": {NumVertices: 1, NumEdges: 2}, "c": {NumVertices: 1, NumEdges: 2}},
+				"b": {"d": {NumEdges: 1}},
+				"c": {"d": {NumEdges: 1}},
+			},
+		},
+		{
+			desc: "unreachable edges are skipped",
+			in: `a b
+c d`,
+			want: map[string]map[string]cutImpact{
+				"a": {"b": {NumVertices: 1, NumEdges: 1}},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			g, err := newGraph(bytes.NewBufferString(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := g.edgeImpacts()
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got different impacts (extraneous -, missing +):\n%s", diff)
+			}
+		})
+	}
+}
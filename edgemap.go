@@ -10,6 +10,11 @@ type edge struct {
 	To   *Vertex
 	// Number of times that from uses to. (AST parsing)
 	NumUsages int
+
+	// AnalysisStatus and AnalysisError say whether NumUsages is known. See
+	// Vertex.
+	AnalysisStatus string
+	AnalysisError  string
 }
 
 func (e *edge) String() string {
@@ -38,11 +43,20 @@ func (em edgeMap) containsLocked(from, to *Vertex) bool {
 	return true
 }
 
-// set creates an edge from-to.
+// set creates an edge from-to. If its usages can't be found, the edge is
+// marked as failed.
 func (em edgeMap) set(from, to *Vertex) {
 	// This can take O(seconds), so let's do it outside the lock.
-	numUsages := astParser.ModuleUsagesForModule(from.Label, to.Label)
+	numUsages, err := astParser.ModuleUsagesForModule(from.Label, to.Label)
+	if err != nil {
+		em.setCopy(from, to, &edge{NumUsages: -1, AnalysisStatus: analysisErrorStatus(err), AnalysisError: err.Error()})
+		return
+	}
+	em.setUsages(from, to, numUsages)
+}
 
+// setUsages creates an edge from-to whose number of usages is already known.
+func (em edgeMap) setUsages(from, to *Vertex, numUsages int) {
 	emMu.Lock()
 	defer emMu.Unlock()
 	if em == nil {
@@ -54,6 +68,22 @@ func (em edgeMap) set(from, to *Vertex) {
 	em[from.Label][to.Label] = &edge{From: from, To: to, NumUsages: numUsages}
 }
 
+// setCopy creates an edge from-to with e's usages and analysis status.
+func (em edgeMap) setCopy(from, to *Vertex, e *edge) {
+	emMu.Lock()
+	defer emMu.Unlock()
+	if _, ok := em[from.Label]; !ok {
+		em[from.Label] = make(map[string]*edge)
+	}
+	em[from.Label][to.Label] = &edge{
+		From:           from,
+		To:             to,
+		NumUsages:      e.NumUsages,
+		AnalysisStatus: e.AnalysisStatus,
+		AnalysisError:  e.AnalysisError,
+	}
+}
+
 // remove removes the edge from-to.
 func (em edgeMap) remove(from, to *Vertex) error {
 	emMu.Lock()
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// exportedGraph is a graph in a form that other tools can read. Its JSON
+// encoding is lean's documented export schema:
+//
+//	{
+//	  "root": "example.com/m",
+//	  "vertices": [
+//	    {"id": "example.com/m", "sizeBytes": 100, "retainedBytes": 300},
+//	    {"id": "example.com/d@v1.0.0", "sizeBytes": 200, "retainedBytes": 200, "pruned": true}
+//	  ],
+//	  "edges": [
+//	    {"from": "example.com/m", "to": "example.com/d@v1.0.0", "numUsages": 3, "cut": true}
+//	  ]
+//	}
+//
+// Vertices and edges are sorted by id, and by from and then to. Sizes and
+// usages are -1 where they're unknown.
+type exportedGraph struct {
+	Root     string           `json:"root"`
+	Vertices []exportedVertex `json:"vertices"`
+	Edges    []exportedEdge   `json:"edges"`
+}
+
+// exportedVertex is a vertex in an exportedGraph.
+type exportedVertex struct {
+	// ID is the vertex's label.
+	ID        string `json:"id"`
+	SizeBytes int64  `json:"sizeBytes"`
+
+	// RetainedBytes is as in Vertex, with the cut edges put back.
+	RetainedBytes int64 `json:"retainedBytes"`
+
+	// Pruned is whether the vertex is only reachable through cut edges.
+	Pruned bool `json:"pruned,omitempty"`
+}
+
+// exportedEdge is an edge in an exportedGraph.
+type exportedEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	NumUsages int    `json:"numUsages"`
+
+	// Cut is whether the edge has been cut.
+	Cut bool `json:"cut,omitempty"`
+}
+
+// export returns what's reachable from g's root. If cuts isn't empty, the
+// edges in it are put back and marked as cut, and what they alone reach is
+// marked as pruned.
+func (g *graph) export(cuts edgeMap) (*exportedGraph, error) {
+	full := g.copy()
+	for _, tos := range cuts {
+		for _, e := range tos {
+			if err := full.addEdgeCopy(e); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	g.mu.Lock()
+	reachable := g.reachableLocked(nil)
+	g.mu.Unlock()
+	full.mu.Lock()
+	defer full.mu.Unlock()
+	full.dominatorsLocked()
+
+	out := &exportedGraph{Root: g.root, Vertices: []exportedVertex{}, Edges: []exportedEdge{}}
+	for from := range full.reachableLocked(nil) {
+		v := full.vertices[from]
+		_, ok := reachable[from]
+		out.Vertices = append(out.Vertices, exportedVertex{ID: from, SizeBytes: v.SizeBytes, RetainedBytes: v.RetainedBytes, Pruned: !ok})
+		for to, e := range (*full.edges)[from] {
+			_, cut := cuts[from][to]
+			out.Edges = append(out.Edges, exportedEdge{From: from, To: to, NumUsages: e.NumUsages, Cut: cut})
+		}
+	}
+	sort.Slice(out.Vertices, func(i, j int) bool { return out.Vertices[i].ID < out.Vertices[j].ID })
+	sort.Slice(out.Edges, func(i, j int) bool {
+		if out.Edges[i].From != out.Edges[j].From {
+			return out.Edges[i].From < out.Edges[j].From
+		}
+		return out.Edges[i].To < out.Edges[j].To
+	})
+	return out, nil
+}
+
+// Export formats.
+const (
+	exportDOT     = "dot"
+	exportGraphML = "graphml"
+	exportMermaid = "mermaid"
+	exportJSON    = "json"
+)
+
+// exportContentTypes are the content types of the export formats.
+var exportContentTypes = map[string]string{
+	exportDOT:     "text/vnd.graphviz",
+	exportGraphML: "application/graphml+xml",
+	exportMermaid: "text/plain",
+	exportJSON:    "application/json",
+}
+
+// writeExport writes eg to w in the given format.
+func writeExport(w io.Writer, eg *exportedGraph, format string) error {
+	switch format {
+	case exportDOT:
+		return writeDOT(w, eg)
+	case exportGraphML:
+		return writeGraphML(w, eg)
+	case exportMermaid:
+		return writeMermaid(w, eg)
+	case exportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(eg)
+	default:
+		return fmt.Errorf("unknown export format %q: want %s, %s, %s or %s", format, exportDOT, exportGraphML, exportMermaid, exportJSON)
+	}
+}
+
+// usagesLabel describes an edge's usages, or is empty if they're unknown.
+func usagesLabel(numUsages int) string {
+	switch {
+	case numUsages < 0:
+		return ""
+	case numUsages == 1:
+		return "1 usage"
+	default:
+		return fmt.Sprintf("%d usages", numUsages)
+	}
+}
+
+// writeDOT writes eg as a Graphviz digraph. Cut edges are red and dashed, and
+// pruned vertices are grey and dashed.
+func writeDOT(w io.Writer, eg *exportedGraph) error {
+	var b strings.Builder
+	b.WriteString("digraph lean {\n")
+	for _, v := range eg.Vertices {
+		attrs := []string{"size_bytes=" + strconv.FormatInt(v.SizeBytes, 10)}
+		if v.ID == eg.Root {
+			attrs = append(attrs, "shape=box")
+		}
+		if v.Pruned {
+			attrs = append(attrs, "style=dashed", "color=grey", "fontcolor=grey")
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", strconv.Quote(v.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range eg.Edges {
+		attrs := []string{"usages=" + strconv.Itoa(e.NumUsages)}
+		if l := usagesLabel(e.NumUsages); l != "" {
+			attrs = append(attrs, "label="+strconv.Quote(l))
+		}
+		if e.Cut {
+			attrs = append(attrs, "style=dashed", "color=red")
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// graphML is the root element of a GraphML document.
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// writeGraphML writes eg as a GraphML document, with the vertices' sizes and
+// the edges' usages as data.
+func writeGraphML(w io.Writer, eg *exportedGraph) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "sizeBytes", For: "node", AttrName: "sizeBytes", AttrType: "long"},
+			{ID: "retainedBytes", For: "node", AttrName: "retainedBytes", AttrType: "long"},
+			{ID: "pruned", For: "node", AttrName: "pruned", AttrType: "boolean"},
+			{ID: "numUsages", For: "edge", AttrName: "numUsages", AttrType: "int"},
+			{ID: "cut", For: "edge", AttrName: "cut", AttrType: "boolean"},
+		},
+		Graph: graphMLGraph{ID: eg.Root, EdgeDefault: "directed"},
+	}
+	for _, v := range eg.Vertices {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: v.ID, Data: []graphMLData{
+			{Key: "sizeBytes", Value: strconv.FormatInt(v.SizeBytes, 10)},
+			{Key: "retainedBytes", Value: strconv.FormatInt(v.RetainedBytes, 10)},
+			{Key: "pruned", Value: strconv.FormatBool(v.Pruned)},
+		}})
+	}
+	for _, e := range eg.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: e.From, Target: e.To, Data: []graphMLData{
+			{Key: "numUsages", Value: strconv.Itoa(e.NumUsages)},
+			{Key: "cut", Value: strconv.FormatBool(e.Cut)},
+		}})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeMermaid writes eg as a Mermaid flowchart. Module paths aren't valid
+// Mermaid ids, so vertices are numbered in order and labelled with their ids.
+// Cut edges are dotted, and pruned vertices are dashed.
+func writeMermaid(w io.Writer, eg *exportedGraph) error {
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	ids := make(map[string]string)
+	var pruned []string
+	for i, v := range eg.Vertices {
+		id := "v" + strconv.Itoa(i)
+		ids[v.ID] = id
+		fmt.Fprintf(&b, "\t%s[\"%s\"]\n", id, strings.Replace(v.ID, `"`, "#quot;", -1))
+		if v.Pruned {
+			pruned = append(pruned, id)
+		}
+	}
+	for _, e := range eg.Edges {
+		arrow := "-->"
+		if e.Cut {
+			arrow = "-.->"
+		}
+		if l := usagesLabel(e.NumUsages); l != "" {
+			arrow += "|" + l + "|"
+		}
+		fmt.Fprintf(&b, "\t%s %s %s\n", ids[e.From], arrow, ids[e.To])
+	}
+	if len(pruned) > 0 {
+		b.WriteString("\tclassDef pruned stroke-dasharray: 5 5\n")
+		fmt.Fprintf(&b, "\tclass %s pruned\n", strings.Join(pruned, ","))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExport(t *testing.T) {
+	moduleSizer = mapModuleSizer{"a": 1, "b@v1": 10, "c@v1": 100}
+	astParser = mapASTParser{"a b@v1": 2, "a c@v1": 1, "b@v1 c@v1": 0}
+	defer func() {
+		moduleSizer = &testModuleSizer{}
+		astParser = &testASTParser{}
+	}()
+
+	g, err := newGraph(bytes.NewBufferString("a b@v1\na c@v1\nb@v1 c@v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cut, err := g.edge("a", "b@v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.removeEdge("a", "b@v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	eg, err := g.export(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &exportedGraph{
+		Root: "a",
+		Vertices: []exportedVertex{
+			{ID: "a", SizeBytes: 1, RetainedBytes: 101},
+			{ID: "c@v1", SizeBytes: 100, RetainedBytes: 100},
+		},
+		Edges: []exportedEdge{{From: "a", To: "c@v1", NumUsages: 1}},
+	}
+	if diff := cmp.Diff(eg, want); diff != "" {
+		t.Errorf("got different export without cuts (extraneous -, missing +):\n%s", diff)
+	}
+
+	cuts := edgeMap{}
+	cuts.setUsages(cut.From, cut.To, cut.NumUsages)
+	eg, err = g.export(cuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &exportedGraph{
+		Root: "a",
+		Vertices: []exportedVertex{
+			{ID: "a", SizeBytes: 1, RetainedBytes: 111},
+			{ID: "b@v1", SizeBytes: 10, RetainedBytes: 10, Pruned: true},
+			{ID: "c@v1", SizeBytes: 100, RetainedBytes: 100},
+		},
+		Edges: []exportedEdge{
+			{From: "a", To: "b@v1", NumUsages: 2, Cut: true},
+			{From: "a", To: "c@v1", NumUsages: 1},
+			{From: "b@v1", To: "c@v1", NumUsages: 0},
+		},
+	}
+	if diff := cmp.Diff(eg, want); diff != "" {
+		t.Fatalf("got different export with cuts (extraneous -, missing +):\n%s", diff)
+	}
+
+	var dot bytes.Buffer
+	if err := writeExport(&dot, eg, exportDOT); err != nil {
+		t.Fatal(err)
+	}
+	wantDOT := `digraph lean {
+	"a" [size_bytes=1, shape=box];
+	"b@v1" [size_bytes=10, style=dashed, color=grey, fontcolor=grey];
+	"c@v1" [size_bytes=100];
+	"a" -> "b@v1" [usages=2, label="2 usages", style=dashed, color=red];
+	"a" -> "c@v1" [usages=1, label="1 usage"];
+	"b@v1" -> "c@v1" [usages=0, label="0 usages"];
+}
+`
+	if diff := cmp.Diff(dot.String(), wantDOT); diff != "" {
+		t.Errorf("got different DOT (extraneous -, missing +):\n%s", diff)
+	}
+
+	var mermaid bytes.Buffer
+	if err := writeExport(&mermaid, eg, exportMermaid); err != nil {
+		t.Fatal(err)
+	}
+	wantMermaid := `graph LR
+	v0["a"]
+	v1["b@v1"]
+	v2["c@v1"]
+	v0 -.->|2 usages| v1
+	v0 -->|1 usage| v2
+	v1 -->|0 usages| v2
+	classDef pruned stroke-dasharray: 5 5
+	class v1 pruned
+`
+	if diff := cmp.Diff(mermaid.String(), wantMermaid); diff != "" {
+		t.Errorf("got different Mermaid (extraneous -, missing +):\n%s", diff)
+	}
+
+	var graphml bytes.Buffer
+	if err := writeExport(&graphml, eg, exportGraphML); err != nil {
+		t.Fatal(err)
+	}
+	var doc graphML
+	if err := xml.Unmarshal(graphml.Bytes(), &doc); err != nil {
+		t.Fatalf("GraphML doesn't parse: %v\n%s", err, graphml.String())
+	}
+	if len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 3 {
+		t.Errorf("got %d nodes and %d edges in GraphML, want 3 and 3", len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+	if got := doc.Graph.Edges[0].Data; !cmp.Equal(got, []graphMLData{{Key: "numUsages", Value: "2"}, {Key: "cut", Value: "true"}}) {
+		t.Errorf("got data %v for the cut edge", got)
+	}
+
+	if err := writeExport(&bytes.Buffer{}, eg, "svg"); err == nil {
+		t.Error("got no error for an unknown format")
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math/bits"
+	"sort"
 	"strings"
 	"sync"
+
+	"github.com/jadekler/lean/internal"
 )
 
 type Vertex struct {
@@ -15,10 +19,62 @@ type Vertex struct {
 	// SizeBytes is how much disk space, in bytes, this vertex represents. It is
 	// non-cumulative.
 	SizeBytes int64
+
+	// RetainedBytes is how much disk space, in bytes, would leave the graph if
+	// every edge into this vertex were cut. It includes SizeBytes.
+	RetainedBytes int64
+
+	// RetainedVertices is the number of vertices that would leave the graph if
+	// every edge into this vertex were cut. It includes this vertex.
+	RetainedVertices int
+
+	// SharedBytes is how much disk space, in bytes, is reachable from this
+	// vertex, whether or not other vertices also depend on it. It includes
+	// SizeBytes.
+	SharedBytes int64
+
+	// SharedVertices is the number of vertices reachable from this vertex. It
+	// includes this vertex.
+	SharedVertices int
+
+	// The rest come from `go list -m -json all`, when it's available. It only
+	// describes the modules in the build list, so they're empty for other
+	// versions.
+
+	// Main is whether this is a main module.
+	Main bool
+
+	// Indirect is whether the main module only requires this module
+	// indirectly.
+	Indirect bool
+
+	// Replace is the label of the module that replaces this one, if any. For
+	// directory replacements, it's the directory.
+	Replace string
+
+	// GoVersion is the go version that this module's go.mod declares.
+	GoVersion string
+
+	// Deprecated is this module's deprecation message, if it is deprecated.
+	Deprecated string
+
+	// GoMod is the path to this module's go.mod.
+	GoMod string
+
+	// Module is set in package graphs, to the label of the module that this
+	// package belongs to.
+	Module string
+
+	// AnalysisStatus is analysisPending until the vertex has been sized,
+	// analysisFailed if sizing it failed, and analysisMissing if lean is
+	// offline and the module isn't on the file system. AnalysisError says why
+	// in the last two cases. It's empty once the vertex is sized.
+	AnalysisStatus string
+	AnalysisError  string
 }
 
 func (v *Vertex) String() string {
-	return fmt.Sprintf("{Label: %q, SizeBytes: %d}", v.Label, v.SizeBytes)
+	return fmt.Sprintf("{Label: %q, SizeBytes: %d, RetainedBytes: %d, SharedBytes: %d}", v.Label, v.SizeBytes, v.RetainedBytes, v.SharedBytes)
 }
 
 type graph struct {
@@ -27,6 +83,10 @@ type graph struct {
 	mu       sync.Mutex
 	vertices map[string]*Vertex
 	edges    *edgeMap
+
+	// dom is the dominator tree of the graph. It is built lazily, and reset
+	// whenever the graph is mutated.
+	dom *dominatorTree
 }
 
 func (g *graph) String() string {
@@ -43,11 +103,38 @@ func (g *graph) String() string {
 	return fmt.Sprintf("{root: %s, vertices: %s, edges: %s}", g.root, s, g.edges)
 }
 
-// newGraph creates a new graph.
+// Analysis statuses of vertices and edges.
+const (
+	analysisPending = "pending"
+	analysisFailed  = "failed"
+	analysisMissing = "missing"
+)
+
+// workspaceRoot is the root that lean adds above the roots of several modules'
+// graphs, so that they can be looked at as one graph. It's not a real module,
+// so it has no size and doesn't use its edges.
+const workspaceRoot = "workspace"
+
+// newGraph creates a new graph from `go mod graph` output.
 func newGraph(r io.Reader) (*graph, error) {
-	emWorkers := make(chan bool, 20)
-	emWg := sync.WaitGroup{}
+	return newGraphWith(r, moduleSizer, astParser)
+}
 
+// newGraphWith is newGraph, sizing vertices and analyzing edges with the given
+// sizer and parser rather than moduleSizer and astParser.
+func newGraphWith(r io.Reader, sizer ReplaceableModuleSizer, parser ReplaceableASTParser) (*graph, error) {
+	g, err := parseGraph(r)
+	if err != nil {
+		return nil, err
+	}
+	analyzeGraph(g, sizer, parser, g.applyAnalysis)
+	return g, nil
+}
+
+// parseGraph creates a graph from `go mod graph` output without sizing its
+// vertices or analyzing its edges: their sizes and usages are -1, and their
+// analysis is pending, until analyzeGraph finds them.
+func parseGraph(r io.Reader) (*graph, error) {
 	g := &graph{vertices: make(map[string]*Vertex), edges: &edgeMap{}}
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
@@ -62,36 +149,29 @@ func newGraph(r io.Reader) (*graph, error) {
 		from := parts[0]
 		to := parts[1]
 
-		if _, ok := g.vertices[from]; !ok {
-			sizeBytes, err := moduleSizer.ModuleSize(from)
-			if err != nil {
-				return nil, err
-			}
-			g.vertices[from] = &Vertex{Label: from, SizeBytes: sizeBytes}
+		// Newer versions of go list the go version and toolchain that a
+		// module requires as if they were modules. They aren't.
+		if isToolchain(from) || isToolchain(to) {
+			continue
 		}
-		if _, ok := g.vertices[to]; !ok {
-			sizeBytes, err := moduleSizer.ModuleSize(to)
-			if err != nil {
-				return nil, err
+
+		for _, label := range []string{from, to} {
+			if _, ok := g.vertices[label]; ok {
+				continue
+			}
+			v := &Vertex{Label: label, SizeBytes: -1, AnalysisStatus: analysisPending}
+			if label == workspaceRoot {
+				v.SizeBytes = 0
+				v.AnalysisStatus = ""
 			}
-			g.vertices[to] = &Vertex{Label: to, SizeBytes: sizeBytes}
+			g.vertices[label] = v
 		}
 
-		fromV := g.vertices[from]
-		toV := g.vertices[to]
-
-		// set performs ast calculations, which takes O(seconds). So, let's
-		// do so in a goroutine.
-		//
-		// TODO(deklerk) Should this happen elsewhere, so that these goroutine
-		// and mutex interactions are a bit more clear?
-		emWorkers <- true
-		emWg.Add(1)
-		go func() {
-			g.edges.set(fromV, toV)
-			emWg.Done()
-			<-emWorkers
-		}()
+		e := &edge{NumUsages: -1, AnalysisStatus: analysisPending}
+		if from == workspaceRoot {
+			e = &edge{NumUsages: 0}
+		}
+		g.edges.setCopy(g.vertices[from], g.vertices[to], e)
 
 		// `go mod graph` always presents the root as the first "from" node
 		if g.root == "" {
@@ -101,10 +181,37 @@ func newGraph(r io.Reader) (*graph, error) {
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
-	emWg.Wait()
 	return g, nil
 }
 
+// annotateModules copies what `go list -m -json all` says about each module
+// onto its vertex. Modules that aren't in g are ignored.
+func (g *graph) annotateModules(modules []*internal.Module) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, m := range modules {
+		v, ok := g.vertices[m.Label()]
+		if !ok {
+			continue
+		}
+		v.Main = m.Main
+		v.Indirect = m.Indirect
+		v.GoVersion = m.GoVersion
+		v.Deprecated = m.Deprecated
+		v.GoMod = m.GoMod
+		if m.Replace != nil {
+			v.Replace = m.Replace.Label()
+		}
+	}
+}
+
+// isToolchain returns whether the vertex label is one of the go@version or
+// toolchain@version entries in `go mod graph` output.
+func isToolchain(label string) bool {
+	return strings.HasPrefix(label, "go@") || strings.HasPrefix(label, "toolchain@")
+}
+
 // copy creates a copy of g.
 func (g *graph) copy() *graph {
 	g.mu.Lock()
@@ -115,13 +222,16 @@ func (g *graph) copy() *graph {
 		vertices: make(map[string]*Vertex),
 		edges:    &edgeMap{},
 	}
+	// Vertices are copied, rather than shared, since their retained and shared
+	// sizes depend on the graph they're in.
 	for k, v := range g.vertices {
-		newg.vertices[k] = v
+		newv := *v
+		newg.vertices[k] = &newv
 	}
 
 	for _, edges := range *g.edges {
 		for _, edge := range edges {
-			newg.edges.set(edge.From, edge.To)
+			newg.edges.setCopy(newg.vertices[edge.From.Label], newg.vertices[edge.To.Label], edge)
 		}
 	}
 
@@ -140,9 +250,45 @@ func (g *graph) addEdge(from, to string) error {
 		return fmt.Errorf("vertex %s does not exist", to)
 	}
 	g.edges.set(g.vertices[from], g.vertices[to])
+	g.dom = nil
 	return nil
 }
 
+// addEdgeCopy adds a copy of an edge whose number of usages is already known,
+// such as one that was cut and is being restored, to the graph.
+func (g *graph) addEdgeCopy(e *edge) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	from, to := e.From.Label, e.To.Label
+	if _, ok := g.vertices[from]; !ok {
+		return fmt.Errorf("vertex %s does not exist", from)
+	}
+	if _, ok := g.vertices[to]; !ok {
+		return fmt.Errorf("vertex %s does not exist", to)
+	}
+	g.edges.setCopy(g.vertices[from], g.vertices[to], e)
+	g.dom = nil
+	return nil
+}
+
+// edge returns the from-to edge.
+func (g *graph) edge(from, to string) (*edge, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.vertices[from]; !ok {
+		return nil, fmt.Errorf("vertex %s does not exist", from)
+	}
+	if _, ok := g.vertices[to]; !ok {
+		return nil, fmt.Errorf("vertex %s does not exist", to)
+	}
+	if !g.edges.containsLocked(g.vertices[from], g.vertices[to]) {
+		return nil, fmt.Errorf("edge (%s, %s) does not exist", from, to)
+	}
+	return (*g.edges)[from][to], nil
+}
+
 // removeEdge removes an edge from the graph.
 func (g *graph) removeEdge(from, to string) error {
 	g.mu.Lock()
@@ -154,14 +300,59 @@ func (g *graph) removeEdge(from, to string) error {
 	if _, ok := g.vertices[to]; !ok {
 		return fmt.Errorf("vertex %s does not exist", to)
 	}
-	return g.edges.remove(g.vertices[from], g.vertices[to])
+	if err := g.edges.remove(g.vertices[from], g.vertices[to]); err != nil {
+		return err
+	}
+	g.dom = nil
+	return nil
+}
+
+// removeVertex removes every edge into the vertex, and returns the removed
+// edges.
+func (g *graph) removeVertex(label string) (edgeMap, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.vertices[label]; !ok {
+		return nil, fmt.Errorf("vertex %s does not exist", label)
+	}
+	if label == g.root {
+		return nil, fmt.Errorf("can't remove the root %s", label)
+	}
+
+	removed := g.inEdgesLocked(label)
+	for from := range removed {
+		if err := g.edges.remove(g.vertices[from], g.vertices[label]); err != nil {
+			return nil, err
+		}
+	}
+	g.dom = nil
+	return removed, nil
+}
+
+// inEdgesLocked returns every edge into the vertex.
+//
+// g.mu must be held.
+func (g *graph) inEdgesLocked(label string) edgeMap {
+	in := edgeMap{}
+	for from, tos := range *g.edges {
+		if e, ok := tos[label]; ok {
+			in[from] = map[string]*edge{label: e}
+		}
+	}
+	return in
 }
 
 // connected returns the subgraph that is reachable from root.
+//
+// Since its result is what gets displayed, it also brings the retained and
+// shared sizes of g's vertices up to date.
 func (g *graph) connected(root string) edgeMap {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	g.dominatorsLocked()
+
 	sub := edgeMap{}
 	seenVertices := make(map[string]struct{})
 	var dfs func(from string)
@@ -174,7 +365,7 @@ func (g *graph) connected(root string) edgeMap {
 		}
 		seenVertices[from] = struct{}{}
 		for _, e := range (*g.edges)[from] {
-			sub.set(e.From, e.To)
+			sub.setCopy(e.From, e.To, e)
 			dfs(e.To.Label)
 		}
 	}
@@ -186,63 +377,358 @@ func (g *graph) connected(root string) edgeMap {
 // from the graph if the given (from, to) edge were cut. These lists may be
 // empty but will not be nil.
 //
-// TODO(deklerk): This is inefficient and should be converted to Lengauer-Tarjan
-// https://www.cs.au.dk/~gerth/advising/thesis/henrik-knakkegaard-christensen.pdf.
+// It is answered from g's dominator tree, so repeated calls between mutations
+// don't walk the graph.
 func (g *graph) hypotheticalCut(from, to string) (edgeMap, []string, error) {
 	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	if _, ok := g.vertices[from]; !ok {
-		g.mu.Unlock()
 		return nil, nil, fmt.Errorf("vertex %s does not exist", from)
 	}
 	if _, ok := g.vertices[to]; !ok {
-		g.mu.Unlock()
 		return nil, nil, fmt.Errorf("vertex %s does not exist", to)
 	}
 	if !g.edges.containsLocked(g.vertices[from], g.vertices[to]) {
-		g.mu.Unlock()
 		return nil, nil, fmt.Errorf("edge (%s, %s) does not exist", from, to)
 	}
-	g.mu.Unlock()
 
-	cut := g.copy()
-	if err := cut.removeEdge(from, to); err != nil {
-		return nil, nil, err
+	dt := g.dominatorsLocked()
+	i := dt.edgeIndex[from][to]
+	if !dt.reachable(i) {
+		// The edge is already disconnected from the root, so cutting it only
+		// removes the edge itself.
+		cutEdges := edgeMap{from: {to: (*g.edges)[from][to]}}
+		return cutEdges, []string{}, nil
 	}
+	cutEdges, cutVertices := dt.subtree(i)
+	return cutEdges, cutVertices, nil
+}
 
-	a := cut.connected(cut.root)
-	b := cut.connected(to)
+// hypotheticalVertexCut returns a list of edges and vertices that would be
+// pruned from the graph if every edge into the given vertex were cut. These
+// lists may be empty but will not be nil.
+func (g *graph) hypotheticalVertexCut(label string) (edgeMap, []string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 
-	av := a.vertices()
-	bv := b.vertices()
+	if _, ok := g.vertices[label]; !ok {
+		return nil, nil, fmt.Errorf("vertex %s does not exist", label)
+	}
+	if label == g.root {
+		return nil, nil, fmt.Errorf("can't remove the root %s", label)
+	}
 
-	cutEdges := a.negativeComplement(b)
-	cutEdges.set(g.vertices[from], g.vertices[to]) // add the recently cut edge
+	cutEdges, cutVertices := edgeMap{}, []string{}
+	dt := g.dominatorsLocked()
+	if i := dt.vertexIndex[label]; dt.reachable(i) {
+		cutEdges, cutVertices = dt.subtree(i)
+	}
+	for from, tos := range g.inEdgesLocked(label) {
+		if _, ok := cutEdges[from]; !ok {
+			cutEdges[from] = make(map[string]*edge)
+		}
+		cutEdges[from][label] = tos[label]
+	}
+	return cutEdges, cutVertices, nil
+}
 
-	// Kind of hacky workaround for the fact that when b has no downstream
-	// edges, the cut edgeMap has no vertices (because it only tracks edges,
-	// which there are none of).
-	cutVertices := negativeComplementVertices(av, bv)
-	if len(cutVertices) == 0 {
-		cutVertices = append(cutVertices, to)
+// hypotheticalCuts returns a list of edges and vertices that would be pruned
+// from the graph if all of the given edges were cut together. These lists may
+// be empty but will not be nil.
+//
+// Unlike hypotheticalCut, this can't be answered from the dominator tree: a
+// vertex with two parents survives either cut alone, but not both.
+func (g *graph) hypotheticalCuts(cuts map[string]map[string]struct{}) (edgeMap, []string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for from, tos := range cuts {
+		for to := range tos {
+			if _, ok := g.vertices[from]; !ok {
+				return nil, nil, fmt.Errorf("vertex %s does not exist", from)
+			}
+			if _, ok := g.vertices[to]; !ok {
+				return nil, nil, fmt.Errorf("vertex %s does not exist", to)
+			}
+			if !g.edges.containsLocked(g.vertices[from], g.vertices[to]) {
+				return nil, nil, fmt.Errorf("edge (%s, %s) does not exist", from, to)
+			}
+		}
 	}
 
+	before := g.reachableLocked(nil)
+	after := g.reachableLocked(cuts)
+
+	cutEdges := edgeMap{}
+	for from := range before {
+		for to, e := range (*g.edges)[from] {
+			_, cut := cuts[from][to]
+			if _, ok := after[from]; ok && !cut {
+				continue
+			}
+			if _, ok := cutEdges[from]; !ok {
+				cutEdges[from] = make(map[string]*edge)
+			}
+			cutEdges[from][to] = e
+		}
+	}
+	cutVertices := []string{}
+	for v := range before {
+		if _, ok := after[v]; !ok {
+			cutVertices = append(cutVertices, v)
+		}
+	}
+	sort.Strings(cutVertices)
 	return cutEdges, cutVertices, nil
 }
 
-// Returns !(a \ b).
-// https://en.wikipedia.org/wiki/Complement_(set_theory)
-func negativeComplementVertices(a, b []*Vertex) []string {
-	amap := make(map[*Vertex]struct{})
-	for _, v := range a {
-		amap[v] = struct{}{}
+// cutPlan is the result of evaluating several edge cuts together.
+type cutPlan struct {
+	// Edges and Vertices are everything pruned by the plan, including the cut
+	// edges themselves.
+	Edges    edgeMap
+	Vertices []string
+
+	Impact cutImpact
+
+	// Modules are the modules that the plan removes entirely, in package
+	// graphs. See prunedModules.
+	Modules []string
+
+	// Redundant are the edges in the plan that could be dropped from it
+	// without changing which vertices are pruned: for example, because
+	// another cut in the plan already disconnects them.
+	Redundant edgeMap
+}
+
+// evaluatePlan evaluates cutting all of the given edges together.
+func (g *graph) evaluatePlan(cuts map[string]map[string]struct{}) (*cutPlan, error) {
+	edges, vertices, err := g.hypotheticalCuts(cuts)
+	if err != nil {
+		return nil, err
+	}
+	plan := &cutPlan{
+		Edges:     edges,
+		Vertices:  vertices,
+		Impact:    g.impact(edges, vertices),
+		Modules:   g.prunedModules(vertices),
+		Redundant: edgeMap{},
 	}
 
-	out := []string{}
-	for _, v := range b {
-		if _, ok := amap[v]; ok {
-			continue
+	for from, tos := range cuts {
+		for to := range tos {
+			without := make(map[string]map[string]struct{})
+			for f, ts := range cuts {
+				without[f] = make(map[string]struct{})
+				for t := range ts {
+					if f != from || t != to {
+						without[f][t] = struct{}{}
+					}
+				}
+			}
+			_, withoutVertices, err := g.hypotheticalCuts(without)
+			if err != nil {
+				return nil, err
+			}
+			// Cutting fewer edges can only prune a subset of what the whole
+			// plan prunes, so the same count means the same vertices.
+			if len(withoutVertices) != len(vertices) {
+				continue
+			}
+			if _, ok := plan.Redundant[from]; !ok {
+				plan.Redundant[from] = make(map[string]*edge)
+			}
+			g.mu.Lock()
+			plan.Redundant[from][to] = (*g.edges)[from][to]
+			g.mu.Unlock()
+		}
+	}
+	return plan, nil
+}
+
+// reachableLocked returns the set of vertices reachable from the root without
+// crossing any of the given edges.
+//
+// g.mu must be held.
+func (g *graph) reachableLocked(without map[string]map[string]struct{}) map[string]struct{} {
+	seen := map[string]struct{}{g.root: {}}
+	stack := []string{g.root}
+	for len(stack) > 0 {
+		from := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for to := range (*g.edges)[from] {
+			if _, ok := without[from][to]; ok {
+				continue
+			}
+			if _, ok := seen[to]; ok {
+				continue
+			}
+			seen[to] = struct{}{}
+			stack = append(stack, to)
+		}
+	}
+	return seen
+}
+
+// impact sums up the size of the given pruned edges and vertices.
+func (g *graph) impact(cutEdges edgeMap, cutVertices []string) cutImpact {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var out cutImpact
+	for _, tos := range cutEdges {
+		out.NumEdges += len(tos)
+	}
+	for _, l := range cutVertices {
+		out.NumVertices++
+		if s := g.vertices[l].SizeBytes; s > 0 {
+			out.SizeBytes += s
 		}
-		out = append(out, v.Label)
 	}
 	return out
 }
+
+// edgeImpacts returns the impact of cutting each edge that is reachable from
+// g's root, computed in a single pass over g's dominator tree.
+func (g *graph) edgeImpacts() map[string]map[string]cutImpact {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.dominatorsLocked().edgeImpacts()
+}
+
+// dominatorsLocked returns g's dominator tree, building it if g has been
+// mutated since it was last built.
+//
+// g.mu must be held.
+func (g *graph) dominatorsLocked() *dominatorTree {
+	if g.dom == nil {
+		g.dom = newDominatorTree(g)
+		g.updateSizesLocked()
+	}
+	return g.dom
+}
+
+// updateSizesLocked recalculates the retained and shared sizes of every vertex
+// in g. Vertices that aren't reachable from the root retain and share
+// nothing.
+//
+// g.mu must be held, and g.dom must be up to date.
+func (g *graph) updateSizesLocked() {
+	for label, v := range g.vertices {
+		v.RetainedBytes, v.RetainedVertices = 0, 0
+		v.SharedBytes, v.SharedVertices = 0, 0
+
+		i := g.dom.vertexIndex[label]
+		if !g.dom.reachable(i) {
+			continue
+		}
+		v.RetainedBytes = g.dom.impact[i].SizeBytes
+		v.RetainedVertices = g.dom.impact[i].NumVertices
+	}
+	g.updateSharedSizesLocked()
+}
+
+// updateSharedSizesLocked sets the shared sizes of every vertex reachable from
+// the root. Rather than search from each vertex, it finds the strongly
+// connected components of the graph with Tarjan's algorithm: every vertex in a
+// component reaches the same vertices, and a component's reachable set is its
+// own vertices plus those of the components that it has edges to, which
+// Tarjan's algorithm finishes first.
+//
+// g.mu must be held.
+func (g *graph) updateSharedSizesLocked() {
+	ids := make(map[string]int, len(g.vertices))
+	labels := make([]string, 0, len(g.vertices))
+	for l := range g.vertices {
+		ids[l] = len(labels)
+		labels = append(labels, l)
+	}
+	n := len(labels)
+	words := (n + 63) / 64
+
+	// order is 1 + the order in which each vertex was visited, or 0 if it
+	// hasn't been.
+	order := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	component := make([]int, n)
+	var reach [][]uint64
+	var stack []int
+	next := 1
+
+	var visit func(v int)
+	visit = func(v int) {
+		order[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+		for to := range (*g.edges)[labels[v]] {
+			w, ok := ids[to]
+			if !ok {
+				continue
+			}
+			if order[w] == 0 {
+				visit(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			} else if onStack[w] && order[w] < low[v] {
+				low[v] = order[w]
+			}
+		}
+		if low[v] != order[v] {
+			return
+		}
+
+		// v is the first vertex visited in its component, which is the rest
+		// of the stack down to v.
+		c := len(reach)
+		set := make([]uint64, words)
+		var members []int
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component[w] = c
+			set[w/64] |= 1 << uint(w%64)
+			members = append(members, w)
+			if w == v {
+				break
+			}
+		}
+		for _, m := range members {
+			for to := range (*g.edges)[labels[m]] {
+				w, ok := ids[to]
+				if !ok || component[w] == c {
+					continue
+				}
+				for i, word := range reach[component[w]] {
+					set[i] |= word
+				}
+			}
+		}
+		reach = append(reach, set)
+
+		var sharedBytes int64
+		var sharedVertices int
+		for i, word := range set {
+			sharedVertices += bits.OnesCount64(word)
+			for word != 0 {
+				j := bits.TrailingZeros64(word)
+				word &^= 1 << uint(j)
+				if s := g.vertices[labels[i*64+j]].SizeBytes; s > 0 {
+					sharedBytes += s
+				}
+			}
+		}
+		for _, m := range members {
+			g.vertices[labels[m]].SharedBytes = sharedBytes
+			g.vertices[labels[m]].SharedVertices = sharedVertices
+		}
+	}
+	if root, ok := ids[g.root]; ok {
+		visit(root)
+	}
+}
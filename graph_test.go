@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jadekler/lean/internal"
 )
 
 // Implements ReplaceableModuleSizer.
@@ -14,11 +16,25 @@ func (*testModuleSizer) ModuleSize(string) (int64, error) {
 	return -1, nil
 }
 
+// Implements ReplaceableModuleSizer with fixed sizes. Unknown modules are -1.
+type mapModuleSizer map[string]int64
+
+func (m mapModuleSizer) ModuleSize(module string) (int64, error) {
+	if s, ok := m[module]; ok {
+		return s, nil
+	}
+	return -1, nil
+}
+
+// ignoreSizes ignores the vertex fields that depend on the shape of the graph,
+// for tests that only care about which edges and vertices are present.
+var ignoreSizes = cmpopts.IgnoreFields(Vertex{}, "RetainedBytes", "RetainedVertices", "SharedBytes", "SharedVertices")
+
 // Implements ReplaceableASTParser.
 type testASTParser struct{}
 
-func (*testASTParser) ModuleUsagesForModule(string, string) int {
-	return 0
+func (*testASTParser) ModuleUsagesForModule(string, string) (int, error) {
+	return 0, nil
 }
 
 func TestHypotheticalCut(t *testing.T) {
@@ -53,6 +69,45 @@ github.com/bar github.com/gaz`,
 			},
 			wantVertices: []string{"github.com/bar", "github.com/gaz"},
 		},
+		{
+			desc: "diamond keeps shared child",
+			in: `a b
+a c
+b d
+c d`,
+			cutFrom: "a",
+			cutTo:   "b",
+			wantEdges: edgeMap{
+				"a": makeEdge("a", "b"),
+				"b": makeEdge("b", "d"),
+			},
+			wantVertices: []string{"b"},
+		},
+		{
+			desc: "still reachable vertex is not removed",
+			in: `a b
+a c
+c b
+b d`,
+			cutFrom:      "a",
+			cutTo:        "b",
+			wantEdges:    edgeMap{"a": makeEdge("a", "b")},
+			wantVertices: []string{},
+		},
+		{
+			desc: "cycle below cut is removed",
+			in: `a b
+b c
+c b`,
+			cutFrom: "a",
+			cutTo:   "b",
+			wantEdges: edgeMap{
+				"a": makeEdge("a", "b"),
+				"b": makeEdge("b", "c"),
+				"c": makeEdge("c", "b"),
+			},
+			wantVertices: []string{"b", "c"},
+		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
 			b := bytes.Buffer{}
@@ -67,7 +122,7 @@ github.com/bar github.com/gaz`,
 			if err != nil {
 				t.Fatal(err)
 			}
-			if diff := cmp.Diff(gotEdges, tc.wantEdges); diff != "" {
+			if diff := cmp.Diff(gotEdges, tc.wantEdges, ignoreSizes); diff != "" {
 				t.Errorf("got different edges (extraneous -, missing +):\n%s", diff)
 			}
 			if diff := cmp.Diff(gotVertices, tc.wantVertices); diff != "" {
@@ -77,6 +132,155 @@ github.com/bar github.com/gaz`,
 	}
 }
 
+func TestHypotheticalVertexCut(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	for _, tc := range []struct {
+		desc         string
+		in           string
+		vertex       string
+		wantEdges    edgeMap
+		wantVertices []string
+	}{
+		{
+			desc:   "vertex with two parents",
+			in:     "a b\na c\nb d\nc d\nd e",
+			vertex: "d",
+			wantEdges: edgeMap{
+				"b": makeEdge("b", "d"),
+				"c": makeEdge("c", "d"),
+				"d": makeEdge("d", "e"),
+			},
+			wantVertices: []string{"d", "e"},
+		},
+		{
+			desc:   "shared children survive",
+			in:     "a b\na c\nb c",
+			vertex: "b",
+			wantEdges: edgeMap{
+				"a": makeEdge("a", "b"),
+				"b": makeEdge("b", "c"),
+			},
+			wantVertices: []string{"b"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			g, err := newGraph(bytes.NewBufferString(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotEdges, gotVertices, err := g.hypotheticalVertexCut(tc.vertex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(gotEdges, tc.wantEdges, ignoreSizes); diff != "" {
+				t.Errorf("got different edges (extraneous -, missing +):\n%s", diff)
+			}
+			if diff := cmp.Diff(gotVertices, tc.wantVertices); diff != "" {
+				t.Errorf("got different vertices (extraneous -, missing +):\n%s", diff)
+			}
+
+			// Actually removing the vertex should prune the same vertices.
+			if _, err := g.removeVertex(tc.vertex); err != nil {
+				t.Fatal(err)
+			}
+			g.mu.Lock()
+			reachable := g.reachableLocked(nil)
+			g.mu.Unlock()
+			for _, v := range tc.wantVertices {
+				if _, ok := reachable[v]; ok {
+					t.Errorf("%s is still connected after removing %s", v, tc.vertex)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveVertexRoot(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	g, err := newGraph(bytes.NewBufferString("a b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.removeVertex("a"); err == nil {
+		t.Error("expected error removing the root, got nil")
+	}
+}
+
+func TestEvaluatePlan(t *testing.T) {
+	moduleSizer = mapModuleSizer{"b": 1, "c": 10, "d": 100}
+	astParser = &testASTParser{}
+	defer func() { moduleSizer = &testModuleSizer{} }()
+
+	for _, tc := range []struct {
+		desc          string
+		in            string
+		cuts          map[string]map[string]struct{}
+		wantVertices  []string
+		wantImpact    cutImpact
+		wantRedundant [][2]string
+	}{
+		{
+			desc: "vertex with two parents needs both cut",
+			in:   "a b\na c\nb d\nc d",
+			cuts: map[string]map[string]struct{}{
+				"b": {"d": {}},
+				"c": {"d": {}},
+			},
+			wantVertices: []string{"d"},
+			wantImpact:   cutImpact{SizeBytes: 100, NumVertices: 1, NumEdges: 2},
+		},
+		{
+			desc: "cut below another cut is redundant",
+			in:   "a b\nb c\nc d",
+			cuts: map[string]map[string]struct{}{
+				"a": {"b": {}},
+				"c": {"d": {}},
+			},
+			wantVertices:  []string{"b", "c", "d"},
+			wantImpact:    cutImpact{SizeBytes: 111, NumVertices: 3, NumEdges: 3},
+			wantRedundant: [][2]string{{"c", "d"}},
+		},
+		{
+			desc: "cut that prunes nothing is redundant",
+			in:   "a b\na c\nc b",
+			cuts: map[string]map[string]struct{}{
+				"a": {"b": {}},
+			},
+			wantVertices:  []string{},
+			wantImpact:    cutImpact{NumEdges: 1},
+			wantRedundant: [][2]string{{"a", "b"}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			g, err := newGraph(bytes.NewBufferString(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan, err := g.evaluatePlan(tc.cuts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(plan.Vertices, tc.wantVertices); diff != "" {
+				t.Errorf("got different vertices (extraneous -, missing +):\n%s", diff)
+			}
+			if plan.Impact != tc.wantImpact {
+				t.Errorf("got impact %+v, want %+v", plan.Impact, tc.wantImpact)
+			}
+			var gotRedundant [][2]string
+			for _, e := range sortedEdges(plan.Redundant) {
+				gotRedundant = append(gotRedundant, [2]string{e.From.Label, e.To.Label})
+			}
+			if diff := cmp.Diff(gotRedundant, tc.wantRedundant); diff != "" {
+				t.Errorf("got different redundant edges (extraneous -, missing +):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestConnected(t *testing.T) {
 	moduleSizer = &testModuleSizer{}
 	astParser = &testASTParser{}
@@ -104,13 +308,127 @@ func TestConnected(t *testing.T) {
 				t.Fatal(err)
 			}
 			got := g.connected(tc.root)
-			if diff := cmp.Diff(got, tc.want); diff != "" {
+			if diff := cmp.Diff(got, tc.want, ignoreSizes); diff != "" {
 				t.Errorf("got different edges (extraneous -, missing +):\n%s", diff)
 			}
 		})
 	}
 }
 
+func TestRetainedAndSharedSizes(t *testing.T) {
+	moduleSizer = mapModuleSizer{"a": 1, "b": 10, "c": 100, "d": 1000}
+	astParser = &testASTParser{}
+	defer func() { moduleSizer = &testModuleSizer{} }()
+
+	type sizes struct {
+		RetainedBytes, SharedBytes       int64
+		RetainedVertices, SharedVertices int
+	}
+	g, err := newGraph(bytes.NewBufferString(`a b
+a c
+b d
+c d`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.connected(g.root)
+
+	got := make(map[string]sizes)
+	for l, v := range g.vertices {
+		got[l] = sizes{
+			RetainedBytes:    v.RetainedBytes,
+			SharedBytes:      v.SharedBytes,
+			RetainedVertices: v.RetainedVertices,
+			SharedVertices:   v.SharedVertices,
+		}
+	}
+	want := map[string]sizes{
+		"a": {RetainedBytes: 1111, SharedBytes: 1111, RetainedVertices: 4, SharedVertices: 4},
+		"b": {RetainedBytes: 10, SharedBytes: 1010, RetainedVertices: 1, SharedVertices: 2},
+		"c": {RetainedBytes: 100, SharedBytes: 1100, RetainedVertices: 1, SharedVertices: 2},
+		"d": {RetainedBytes: 1000, SharedBytes: 1000, RetainedVertices: 1, SharedVertices: 1},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got different sizes (extraneous -, missing +):\n%s", diff)
+	}
+}
+
+func TestSharedSizesWithCycles(t *testing.T) {
+	moduleSizer = mapModuleSizer{"a": 1, "b": 10, "c": 100, "d": 1000, "e": 10000}
+	astParser = &testASTParser{}
+	defer func() { moduleSizer = &testModuleSizer{} }()
+
+	type sizes struct {
+		SharedBytes    int64
+		SharedVertices int
+	}
+	// b and c depend on each other, so they share the same vertices. e isn't
+	// reachable from the root, so it shares nothing.
+	g, err := newGraph(bytes.NewBufferString(`a b
+b c
+c b
+c d
+e d`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.connected(g.root)
+
+	got := make(map[string]sizes)
+	for l, v := range g.vertices {
+		got[l] = sizes{SharedBytes: v.SharedBytes, SharedVertices: v.SharedVertices}
+	}
+	want := map[string]sizes{
+		"a": {SharedBytes: 1111, SharedVertices: 4},
+		"b": {SharedBytes: 1110, SharedVertices: 3},
+		"c": {SharedBytes: 1110, SharedVertices: 3},
+		"d": {SharedBytes: 1000, SharedVertices: 1},
+		"e": {},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got different sizes (extraneous -, missing +):\n%s", diff)
+	}
+}
+
+func TestNewGraphSkipsToolchains(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	g, err := newGraph(bytes.NewBufferString("a go@1.21\na toolchain@go1.21.0\na b\nb go@1.13"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := edgeMap{"a": makeEdge("a", "b")}
+	if diff := cmp.Diff(*g.edges, want, ignoreSizes); diff != "" {
+		t.Errorf("got different edges (extraneous -, missing +):\n%s", diff)
+	}
+	if got, want := g.root, "a"; got != want {
+		t.Errorf("got root %s, want %s", got, want)
+	}
+}
+
+func TestNewGraphWorkspaceRoot(t *testing.T) {
+	moduleSizer = mapModuleSizer{"a": 1, "b": 10}
+	astParser = &failingASTParser{t: t}
+	defer func() {
+		moduleSizer = &testModuleSizer{}
+		astParser = &testASTParser{}
+	}()
+
+	// The workspace root isn't a module, so neither it nor its edges are
+	// analyzed.
+	g, err := newGraph(bytes.NewBufferString(workspaceRoot + " a\n" + workspaceRoot + " b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.root, workspaceRoot; got != want {
+		t.Errorf("got root %s, want %s", got, want)
+	}
+	if got, want := g.vertices[workspaceRoot].SizeBytes, int64(0); got != want {
+		t.Errorf("got workspace root size %d, want %d", got, want)
+	}
+}
+
 func makeEdge(from, to string) map[string]*edge {
 	return map[string]*edge{
 		to: {
@@ -119,3 +437,33 @@ func makeEdge(from, to string) map[string]*edge {
 		},
 	}
 }
+
+func TestAnnotateModules(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	g, err := newGraph(bytes.NewBufferString("a b@v1\na c@v1\nb@v1 c@v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules, err := internal.DecodeModules(bytes.NewBufferString(`
+{"Path": "a", "Main": true, "GoMod": "/a/go.mod", "GoVersion": "1.21"}
+{"Path": "b", "Version": "v1", "Indirect": true, "Replace": {"Path": "../b"}}
+{"Path": "c", "Version": "v2", "Deprecated": "use d"}
+{"Path": "e", "Version": "v1"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.annotateModules(modules)
+
+	want := map[string]*Vertex{
+		"a":    {Label: "a", SizeBytes: -1, Main: true, GoMod: "/a/go.mod", GoVersion: "1.21"},
+		"b@v1": {Label: "b@v1", SizeBytes: -1, Indirect: true, Replace: "../b"},
+		"c@v1": {Label: "c@v1", SizeBytes: -1},
+		"c@v2": {Label: "c@v2", SizeBytes: -1, Deprecated: "use d"},
+	}
+	if diff := cmp.Diff(g.vertices, want, ignoreSizes); diff != "" {
+		t.Errorf("got different vertices (extraneous -, missing +):\n%s", diff)
+	}
+}
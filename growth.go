@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jadekler/lean/internal"
+)
+
+// historyPoint is the size of the module graph as of one commit.
+type historyPoint struct {
+	Commit  string
+	Time    time.Time
+	Subject string
+
+	NumVertices int
+	NumEdges    int
+	SizeBytes   int64
+
+	// Error is why the graph couldn't be computed at this commit, if it
+	// couldn't. The sizes are zero if so.
+	Error string `json:",omitempty"`
+}
+
+// moduleIntroduction is the commit that first brought a module into the
+// graph.
+type moduleIntroduction struct {
+	Path string
+
+	// Version is the version that was selected when the module was introduced.
+	Version string
+
+	Commit string
+	Time   time.Time
+}
+
+// graphHistory is how the module graph grew over a series of commits.
+type graphHistory struct {
+	// Points are the sizes of the graph at each commit, oldest first.
+	Points []historyPoint
+
+	// Introduced are the modules in the graph at any commit, ordered by when
+	// they were introduced and then by path.
+	Introduced []moduleIntroduction
+}
+
+// buildHistory computes the graph at each commit with graphAt, and how it
+// changed from one commit to the next. commits must be oldest first.
+func buildHistory(commits []internal.Commit, graphAt func(internal.Commit) (*graph, error)) *graphHistory {
+	h := &graphHistory{Points: []historyPoint{}, Introduced: []moduleIntroduction{}}
+	seen := make(map[string]bool)
+	for _, c := range commits {
+		p := historyPoint{Commit: c.Hash, Time: c.Time, Subject: c.Subject}
+		g, err := graphAt(c)
+		if err != nil {
+			p.Error = err.Error()
+			h.Points = append(h.Points, p)
+			continue
+		}
+
+		g.mu.Lock()
+		total := g.totalLocked(g.reachableLocked(nil))
+		buildList := g.buildListLocked()
+		g.mu.Unlock()
+		p.NumVertices, p.NumEdges, p.SizeBytes = total.NumVertices, total.NumEdges, total.SizeBytes
+		h.Points = append(h.Points, p)
+
+		var introduced []moduleIntroduction
+		for path, version := range buildList {
+			// Main modules aren't introduced by a requirement.
+			if version == "" || seen[path] {
+				continue
+			}
+			seen[path] = true
+			introduced = append(introduced, moduleIntroduction{Path: path, Version: version, Commit: c.Hash, Time: c.Time})
+		}
+		sort.Slice(introduced, func(i, j int) bool { return introduced[i].Path < introduced[j].Path })
+		h.Introduced = append(h.Introduced, introduced...)
+	}
+	return h
+}
+
+// gitHistory builds the history of the module in dir from the commits that
+// changed its go.mod or go.sum. Each commit's graph is computed offline from
+// its go.mod and go.sum, so modules that aren't in the module cache can't be
+// found, and usages aren't analyzed.
+func gitHistory(dir string) (*graphHistory, error) {
+	commits, err := internal.GitCommits(dir, "go.mod", "go.sum")
+	if err != nil {
+		return nil, err
+	}
+
+	// The same module is usually in many commits' graphs, so sizes are
+	// cached.
+	sizer := &cachingModuleSizer{sizer: &internal.OfflineModuleSizer{}, sizes: make(map[string]int64)}
+	return buildHistory(commits, func(c internal.Commit) (*graph, error) {
+		goMod, err := internal.GitShow(dir, c.Hash, "go.mod")
+		if err != nil {
+			return nil, err
+		}
+		if goMod == nil {
+			return nil, fmt.Errorf("there's no go.mod at %s", c.Hash)
+		}
+		goSum, err := internal.GitShow(dir, c.Hash, "go.sum")
+		if err != nil {
+			return nil, err
+		}
+		out, err := internal.OfflineModGraph(goMod, goSum)
+		if err != nil {
+			return nil, err
+		}
+		return newGraphWith(bytes.NewReader(out), sizer, unanalyzedASTParser{})
+	}), nil
+}
+
+// cachingModuleSizer remembers the sizes that another sizer finds.
+type cachingModuleSizer struct {
+	sizer ReplaceableModuleSizer
+
+	mu    sync.Mutex
+	sizes map[string]int64
+}
+
+func (s *cachingModuleSizer) ModuleSize(module string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if size, ok := s.sizes[module]; ok {
+		return size, nil
+	}
+	size, err := s.sizer.ModuleSize(module)
+	if err != nil {
+		return -1, err
+	}
+	s.sizes[module] = size
+	return size, nil
+}
+
+// unanalyzedASTParser leaves the usages of every edge unknown, for graphs that
+// only need to be counted.
+type unanalyzedASTParser struct{}
+
+func (unanalyzedASTParser) ModuleUsagesForModule(from, to string) (int, error) {
+	return -1, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jadekler/lean/internal"
+)
+
+func TestBuildHistory(t *testing.T) {
+	moduleSizer = mapModuleSizer{"a": 1, "b@v1": 10, "b@v2": 20, "c@v1": 100}
+	astParser = &testASTParser{}
+	defer func() { moduleSizer = &testModuleSizer{} }()
+
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC) }
+	commits := []internal.Commit{
+		{Hash: "1", Time: day(1), Subject: "add b"},
+		{Hash: "2", Time: day(2), Subject: "broken"},
+		{Hash: "3", Time: day(3), Subject: "bump b"},
+	}
+	graphs := map[string]string{
+		"1": "a b@v1",
+		"3": "a b@v2\nb@v2 c@v1",
+	}
+	got := buildHistory(commits, func(c internal.Commit) (*graph, error) {
+		in, ok := graphs[c.Hash]
+		if !ok {
+			return nil, errors.New("no go.sum")
+		}
+		return newGraph(bytes.NewBufferString(in))
+	})
+
+	want := &graphHistory{
+		Points: []historyPoint{
+			{Commit: "1", Time: day(1), Subject: "add b", NumVertices: 2, NumEdges: 1, SizeBytes: 11},
+			{Commit: "2", Time: day(2), Subject: "broken", Error: "no go.sum"},
+			{Commit: "3", Time: day(3), Subject: "bump b", NumVertices: 3, NumEdges: 2, SizeBytes: 121},
+		},
+		Introduced: []moduleIntroduction{
+			{Path: "b", Version: "v1", Commit: "1", Time: day(1)},
+			{Path: "c", Version: "v1", Commit: "3", Time: day(3)},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got different history (extraneous -, missing +):\n%s", diff)
+	}
+}
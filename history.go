@@ -0,0 +1,136 @@
+package main
+
+import "fmt"
+
+// Kinds of operation.
+const (
+	cutEdge       = "cutEdge"
+	restoreEdge   = "restoreEdge"
+	cutVertex     = "cutVertex"
+	restoreVertex = "restoreVertex"
+)
+
+// operation is a single mutation of a graph and its shopping cart.
+type operation struct {
+	Kind string
+
+	// From and To are set for edge operations.
+	From, To string
+
+	// Vertex is set for vertex operations.
+	Vertex string
+}
+
+// inverse returns the operation that undoes op.
+func (op operation) inverse() operation {
+	inv := op
+	switch op.Kind {
+	case cutEdge:
+		inv.Kind = restoreEdge
+	case restoreEdge:
+		inv.Kind = cutEdge
+	case cutVertex:
+		inv.Kind = restoreVertex
+	case restoreVertex:
+		inv.Kind = cutVertex
+	}
+	return inv
+}
+
+// apply performs op on g, recording it in c.
+func (op operation) apply(g *graph, c *cart) error {
+	switch op.Kind {
+	case cutEdge:
+		e, err := g.edge(op.From, op.To)
+		if err != nil {
+			return err
+		}
+		if err := g.removeEdge(op.From, op.To); err != nil {
+			return err
+		}
+		c.addEdge(e)
+	case restoreEdge:
+		// Only edges that were cut can be restored: on their own, or along
+		// with a vertex, which then stays cut with its other edges. They keep
+		// the usages they were cut with, rather than being analyzed again.
+		if e, ok := c.Edges[op.From][op.To]; ok {
+			if err := g.addEdgeCopy(e); err != nil {
+				return err
+			}
+			c.removeEdge(op.From, op.To)
+		} else if e, ok := c.Vertices[op.To][op.From][op.To]; ok {
+			if err := g.addEdgeCopy(e); err != nil {
+				return err
+			}
+			c.removeVertexEdge(op.From, op.To)
+		} else {
+			return fmt.Errorf("edge (%s, %s) was not cut", op.From, op.To)
+		}
+	case cutVertex:
+		removed, err := g.removeVertex(op.Vertex)
+		if err != nil {
+			return err
+		}
+		c.addVertex(op.Vertex, removed)
+	case restoreVertex:
+		inEdges, ok := c.removeVertex(op.Vertex)
+		if !ok {
+			return fmt.Errorf("vertex %s was not cut", op.Vertex)
+		}
+		for _, tos := range inEdges {
+			if err := g.addEdgeCopy(tos[op.Vertex]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", op.Kind)
+	}
+	return nil
+}
+
+// history is an ordered log of the operations performed on a graph, which can
+// be stepped backwards and forwards.
+type history struct {
+	ops []operation
+
+	// next is the index in ops of the next operation to redo. Everything
+	// before it has been applied.
+	next int
+}
+
+// do applies op to g and c, and records it. Anything that had been undone can
+// no longer be redone.
+func (h *history) do(op operation, g *graph, c *cart) error {
+	if err := op.apply(g, c); err != nil {
+		return err
+	}
+	h.ops = append(h.ops[:h.next], op)
+	h.next++
+	return nil
+}
+
+// undo reverts the last applied operation. It returns false if there is
+// nothing to undo.
+func (h *history) undo(g *graph, c *cart) (bool, error) {
+	if h.next == 0 {
+		return false, nil
+	}
+	if err := h.ops[h.next-1].inverse().apply(g, c); err != nil {
+		return false, err
+	}
+	h.next--
+	return true, nil
+}
+
+// redo reapplies the last undone operation. It returns false if there is
+// nothing to redo.
+func (h *history) redo(g *graph, c *cart) (bool, error) {
+	if h.next == len(h.ops) {
+		return false, nil
+	}
+	if err := h.ops[h.next].apply(g, c); err != nil {
+		return false, err
+	}
+	h.next++
+	return true, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	g, err := newGraph(bytes.NewBufferString("a b\na c\nb d\nc d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newCart()
+	h := &history{}
+
+	hasEdge := func(from, to string) bool {
+		_, err := g.edge(from, to)
+		return err == nil
+	}
+
+	if err := h.do(operation{Kind: cutEdge, From: "a", To: "b"}, g, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.do(operation{Kind: cutVertex, Vertex: "d"}, g, c); err != nil {
+		t.Fatal(err)
+	}
+	if hasEdge("a", "b") || hasEdge("b", "d") || hasEdge("c", "d") {
+		t.Fatal("expected a-b, b-d and c-d to be cut")
+	}
+	if len(c.Vertices) != 1 || len(c.Edges) != 1 {
+		t.Fatalf("got cart %+v, want one vertex and one edge", c)
+	}
+
+	// Undo the vertex cut.
+	if ok, err := h.undo(g, c); err != nil || !ok {
+		t.Fatalf("undo: got %v, %v", ok, err)
+	}
+	if !hasEdge("b", "d") || !hasEdge("c", "d") {
+		t.Error("expected undo to restore b-d and c-d")
+	}
+	if len(c.Vertices) != 0 {
+		t.Errorf("got %d cut vertices after undo, want 0", len(c.Vertices))
+	}
+
+	// Redo it.
+	if ok, err := h.redo(g, c); err != nil || !ok {
+		t.Fatalf("redo: got %v, %v", ok, err)
+	}
+	if hasEdge("b", "d") || hasEdge("c", "d") {
+		t.Error("expected redo to cut b-d and c-d again")
+	}
+
+	// Undo everything, then one step too far.
+	for i := 0; i < 2; i++ {
+		if ok, err := h.undo(g, c); err != nil || !ok {
+			t.Fatalf("undo %d: got %v, %v", i, ok, err)
+		}
+	}
+	if ok, err := h.undo(g, c); err != nil || ok {
+		t.Fatalf("undo with empty history: got %v, %v", ok, err)
+	}
+	if !hasEdge("a", "b") || len(c.Edges) != 0 {
+		t.Error("expected everything to be restored")
+	}
+
+	// A new operation discards what could have been redone.
+	if err := h.do(operation{Kind: cutEdge, From: "a", To: "c"}, g, c); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := h.redo(g, c); err != nil || ok {
+		t.Fatalf("redo after new operation: got %v, %v", ok, err)
+	}
+
+	// Only cut edges can be restored, as POST /edge does: not an edge that
+	// was never in the graph, nor one that's still in it.
+	for _, e := range [][2]string{{"a", "d"}, {"b", "d"}} {
+		if err := h.do(operation{Kind: restoreEdge, From: e[0], To: e[1]}, g, c); err == nil {
+			t.Errorf("restoring %s-%s: got nil error, want one", e[0], e[1])
+		}
+	}
+	if hasEdge("a", "d") {
+		t.Error("expected a-d, which never existed, not to be added")
+	}
+
+	// An edge cut along with a vertex can be restored on its own, leaving the
+	// vertex cut with its other edges.
+	if err := h.do(operation{Kind: cutVertex, Vertex: "d"}, g, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.do(operation{Kind: restoreEdge, From: "b", To: "d"}, g, c); err != nil {
+		t.Fatal(err)
+	}
+	if !hasEdge("b", "d") || hasEdge("c", "d") {
+		t.Error("expected only b-d to be restored")
+	}
+	if _, ok := c.Vertices["d"]["b"]; ok {
+		t.Error("expected b-d to no longer be in the cart")
+	}
+	if err := h.do(operation{Kind: restoreEdge, From: "c", To: "d"}, g, c); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Vertices["d"]; ok {
+		t.Error("expected d to no longer be cut once all its edges are restored")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jadekler/lean/static"
+)
+
+// snapshotMaxPaths is how many paths to each vertex a snapshot keeps for
+// highlighting why it's in the graph. It's lower than defaultMaxPaths to keep
+// reports small.
+const snapshotMaxPaths = 10
+
+// snapshot is everything that the UI asks the server for, computed up front
+// so that the UI can be shown without a server. See writeHTMLReport.
+type snapshot struct {
+	// Responses are the responses to the UI's requests that don't depend on
+	// what's hovered or clicked, keyed by path and query.
+	Responses map[string]interface{}
+
+	// EdgeCuts and VertexCuts are what cutting each edge, or every edge into
+	// each vertex, would prune.
+	EdgeCuts   map[string]map[string]*snapshotCut
+	VertexCuts map[string]*snapshotCut
+
+	// Why are the paths to each vertex, keyed by view and then by the
+	// vertex's label.
+	Why map[string]map[string]*snapshotWhy
+}
+
+// snapshotCut is what a hypothetical cut prunes, as in the /hypotheticalCut
+// response. Edges are [from, to] pairs, rather than edgeMaps, to keep
+// snapshots small.
+type snapshotCut struct {
+	Edges    [][2]string
+	Vertices []string
+	Modules  []string
+}
+
+// snapshotWhy is an explanation, with paths given as the labels along them
+// from the root.
+type snapshotWhy struct {
+	Shortest []string
+	Paths    [][]string
+}
+
+// buildSnapshot snapshots the current scenario.
+//
+// mu must be held.
+func buildSnapshot() (*snapshot, error) {
+	collapsed := userGraph.collapse()
+	s := &snapshot{
+		Responses: map[string]interface{}{
+			"/graph?view=raw":         userGraph.connected(userGraph.root),
+			"/graph?view=collapsed":   collapsed.connected(collapsed.root),
+			"/suggest?view=raw":       userGraph.suggestCuts(0),
+			"/suggest?view=collapsed": collapsed.suggestCuts(0),
+			"/shoppingCart":           shoppingCart,
+			"/scenarios":              summarizeScenarios(),
+		},
+		EdgeCuts:   make(map[string]map[string]*snapshotCut),
+		VertexCuts: make(map[string]*snapshotCut),
+		Why:        make(map[string]map[string]*snapshotWhy),
+	}
+	after := userGraph.buildList()
+	s.Responses["/buildList"] = map[string]interface{}{
+		"buildList": buildListLabels(after),
+		"changes":   buildListChanges(originalGraph.buildList(), after),
+	}
+	if diffBase != nil {
+		s.Responses["/diff"] = diffGraphs(diffBase, originalGraph)
+	}
+	if growth != nil {
+		s.Responses["/history"] = growth
+	}
+
+	newCut := func(cutEdges edgeMap, cutVertices []string) *snapshotCut {
+		c := &snapshotCut{Edges: [][2]string{}, Vertices: cutVertices, Modules: userGraph.prunedModules(cutVertices)}
+		for _, e := range sortedEdges(cutEdges) {
+			c.Edges = append(c.Edges, [2]string{e.From.Label, e.To.Label})
+		}
+		return c
+	}
+	userGraph.mu.Lock()
+	var labels []string
+	var edges [][2]string
+	for from := range userGraph.reachableLocked(nil) {
+		labels = append(labels, from)
+		for to := range (*userGraph.edges)[from] {
+			edges = append(edges, [2]string{from, to})
+		}
+	}
+	userGraph.mu.Unlock()
+	for _, e := range edges {
+		cutEdges, cutVertices, err := userGraph.hypotheticalCut(e[0], e[1])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := s.EdgeCuts[e[0]]; !ok {
+			s.EdgeCuts[e[0]] = make(map[string]*snapshotCut)
+		}
+		s.EdgeCuts[e[0]][e[1]] = newCut(cutEdges, cutVertices)
+	}
+	for _, l := range labels {
+		if l == userGraph.root {
+			continue
+		}
+		cutEdges, cutVertices, err := userGraph.hypotheticalVertexCut(l)
+		if err != nil {
+			return nil, err
+		}
+		s.VertexCuts[l] = newCut(cutEdges, cutVertices)
+	}
+
+	for view, g := range map[string]*graph{"raw": userGraph, "collapsed": collapsed} {
+		s.Why[view] = make(map[string]*snapshotWhy)
+		g.mu.Lock()
+		reachable := g.reachableLocked(nil)
+		g.mu.Unlock()
+		for l := range reachable {
+			ex, err := g.why(l, snapshotMaxPaths)
+			if err != nil {
+				return nil, err
+			}
+			labelsAlong := func(path []*edge) []string {
+				out := []string{g.root}
+				for _, e := range path {
+					out = append(out, e.To.Label)
+				}
+				return out
+			}
+			w := &snapshotWhy{Shortest: labelsAlong(ex.Shortest), Paths: [][]string{}}
+			for _, p := range ex.Paths {
+				w.Paths = append(w.Paths, labelsAlong(p))
+			}
+			s.Why[view][l] = w
+		}
+	}
+	return s, nil
+}
+
+// writeHTMLReport writes a single HTML page that shows s without a server:
+// the UI's assets are inlined, and its requests are answered from s.
+func writeHTMLReport(w io.Writer, s *snapshot) error {
+	// json.Marshal escapes <, > and &, so the snapshot can't end the script
+	// that it's in.
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	inlineScript := func(name string) string {
+		return "<script>\n" + strings.Replace(static.Files[name], "</script", `<\/script`, -1) + "\n</script>"
+	}
+
+	page := static.Files["index.html"]
+	for _, r := range []struct{ old, new string }{
+		{`<link rel="stylesheet" href="static/index.css">`, "<style>\n" + static.Files["index.css"] + "\n</style>"},
+		{`<script src="static/d3.v5.min.js"></script>`, inlineScript("d3.v5.min.js")},
+		{`<script src="static/dagre-d3.min.js"></script>`, inlineScript("dagre-d3.min.js")},
+		// The snapshot must be in place before index.js runs.
+		{`<script src="static/index.js"></script>`, fmt.Sprintf("<script>\nwindow.leanSnapshot = %s\n</script>\n%s", b, inlineScript("index.js"))},
+	} {
+		if !strings.Contains(page, r.old) {
+			return fmt.Errorf("index.html has no %s to inline", r.old)
+		}
+		page = strings.Replace(page, r.old, r.new, 1)
+	}
+	_, err = io.WriteString(w, page)
+	return err
+}
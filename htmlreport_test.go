@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestHTMLReport(t *testing.T) {
+	moduleSizer = mapModuleSizer{"b@v1": 10, "c@v1": 100, "d@v1": 1000}
+	astParser = &testASTParser{}
+	defer func() { moduleSizer = &testModuleSizer{} }()
+
+	g, err := newGraph(bytes.NewBufferString("a b@v1\na c@v1\nb@v1 d@v1\nc@v1 d@v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if err := useGraph(g); err != nil {
+		t.Fatal(err)
+	}
+	if err := opHistory.do(operation{Kind: cutEdge, From: "a", To: "c@v1"}, userGraph, shoppingCart); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := buildSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEdgeCuts := map[string]map[string]*snapshotCut{
+		"a": {"b@v1": {
+			Edges:    [][2]string{{"a", "b@v1"}, {"b@v1", "d@v1"}},
+			Vertices: []string{"b@v1", "d@v1"},
+			Modules:  []string{},
+		}},
+		"b@v1": {"d@v1": {
+			Edges:    [][2]string{{"b@v1", "d@v1"}},
+			Vertices: []string{"d@v1"},
+			Modules:  []string{},
+		}},
+	}
+	opts := cmp.Options{cmpopts.EquateEmpty(), cmpopts.SortSlices(func(a, b string) bool { return a < b })}
+	if diff := cmp.Diff(s.EdgeCuts, wantEdgeCuts, opts); diff != "" {
+		t.Errorf("got different edge cuts (extraneous -, missing +):\n%s", diff)
+	}
+	wantWhy := &snapshotWhy{Shortest: []string{"a", "b@v1", "d@v1"}, Paths: [][]string{{"a", "b@v1", "d@v1"}}}
+	if diff := cmp.Diff(s.Why["raw"]["d@v1"], wantWhy); diff != "" {
+		t.Errorf("got different why (extraneous -, missing +):\n%s", diff)
+	}
+	if _, ok := s.Why["raw"]["c@v1"]; ok {
+		t.Errorf("got why for c@v1, which was cut off")
+	}
+
+	var page bytes.Buffer
+	if err := writeHTMLReport(&page, s); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(page.String(), `src="static/`) || strings.Contains(page.String(), `href="static/`) {
+		t.Errorf("page links to static assets instead of inlining them")
+	}
+	const prefix = "window.leanSnapshot = "
+	i := strings.Index(page.String(), prefix)
+	if i < 0 {
+		t.Fatal("page has no snapshot")
+	}
+	line := strings.SplitN(page.String()[i+len(prefix):], "\n", 2)[0]
+	var decoded snapshot
+	if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+		t.Fatalf("snapshot in page doesn't decode: %v", err)
+	}
+	if diff := cmp.Diff(decoded.EdgeCuts, wantEdgeCuts, opts); diff != "" {
+		t.Errorf("got different edge cuts from the page (extraneous -, missing +):\n%s", diff)
+	}
+}
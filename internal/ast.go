@@ -6,6 +6,7 @@ import (
 	"go/parser"
 	"go/token"
 	"io/ioutil"
+	"os"
 	"strings"
 	"sync"
 )
@@ -25,15 +26,18 @@ var usagesCache map[string]map[string]int = make(map[string]map[string]int)
 // ModuleUsagesForModule finds the number of times each of the given module's
 // module dependencies are referred to.
 //
-// This is a thin cache wrapper around the real thing.
-func (*ASTParser) ModuleUsagesForModule(from, to string) int {
+// This is a thin cache wrapper around the real thing. Failures aren't cached.
+func (*ASTParser) ModuleUsagesForModule(from, to string) (int, error) {
 	cacheMu.Lock()
 	if v, ok := usagesCache[from][to]; ok {
 		cacheMu.Unlock()
-		return v
+		return v, nil
 	}
 	cacheMu.Unlock()
-	numUsages := moduleUsagesForModule(from, to)
+	numUsages, err := moduleUsagesForModule(from, to)
+	if err != nil {
+		return -1, err
+	}
 
 	cacheMu.Lock()
 	defer cacheMu.Unlock()
@@ -43,20 +47,30 @@ func (*ASTParser) ModuleUsagesForModule(from, to string) int {
 	if _, ok := usagesCache[from][to]; !ok {
 		usagesCache[from][to] = numUsages
 	}
-	return usagesCache[from][to]
+	return usagesCache[from][to], nil
 }
 
-func moduleUsagesForModule(from, to string) int {
-	fmt.Printf("Analyzing edge (%s, %s)\n", from, to)
+func moduleUsagesForModule(from, to string) (int, error) {
+	fmt.Fprintf(os.Stderr, "Analyzing edge (%s, %s)\n", from, to)
 
-	moduleRootPath := attemptToFindModuleOnFS(from)
+	moduleRootPath, err := attemptToFindModuleOnFS(from)
+	if err != nil {
+		return -1, err
+	}
 	if moduleRootPath == "" {
-		panic(fmt.Errorf("could not find module %s on file system. try `go get %s`?", from, from))
+		return -1, &ModuleNotFoundError{Module: from}
 	}
 
+	toModuleName, err := moduleNameFromModulePath(to)
+	if err != nil {
+		return -1, err
+	}
+	usages, err := packageUsagesForModule(moduleRootPath)
+	if err != nil {
+		return -1, err
+	}
 	var moduleCount int
-	toModuleName := moduleNameFromModulePath(to)
-	for p, c := range packageUsagesForModule(moduleRootPath) {
+	for p, c := range usages {
 		// This sums all package that looks like the given module.
 		//
 		// TODO(deklerk): This falls down in two places:
@@ -68,15 +82,15 @@ func moduleUsagesForModule(from, to string) int {
 		}
 	}
 
-	return moduleCount
+	return moduleCount, nil
 }
 
 // packageUsagesForModule finds the number of times each of the given module's
 // package dependencies are referred to.
-func packageUsagesForModule(moduleRootPath string) map[string]int {
+func packageUsagesForModule(moduleRootPath string) (map[string]int, error) {
 	files, cleanup, err := moduleFiles(moduleRootPath)
 	if err != nil {
-		panic(fmt.Errorf("error getting module files: %s", err))
+		return nil, fmt.Errorf("error getting module files: %s", err)
 	}
 	defer cleanup()
 
@@ -85,30 +99,38 @@ func packageUsagesForModule(moduleRootPath string) map[string]int {
 	for _, f := range files {
 		outBytes, err := ioutil.ReadFile(f)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 
-		for k, v := range PackageUsages(string(outBytes)) {
+		usages, err := PackageUsages(string(outBytes))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		for k, v := range usages {
 			moduleUsages[k] += v
 		}
 	}
 
-	for _, moduleName := range indirectModules(moduleRootPath) {
+	indirect, err := indirectModules(moduleRootPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, moduleName := range indirect {
 		moduleUsages[moduleName] = 1
 	}
 
-	return moduleUsages
+	return moduleUsages, nil
 }
 
 // PackageUsages analyzes the given code, records the imported package, and
 // counts the number of times that each imported package is used.
 //
-// It panics if it encounters a problem. (for better stacktraces heh)
-func PackageUsages(src string) map[string]int {
+// If the code can't be parsed, it returns a *ParseError.
+func PackageUsages(src string) (map[string]int, error) {
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, "does-not-seem-to-matter.go", src, 0)
 	if err != nil {
-		panic(err)
+		return nil, &ParseError{Err: err}
 	}
 
 	out := map[string]int{}
@@ -162,5 +184,5 @@ func PackageUsages(src string) map[string]int {
 		out[longName] = usages
 	}
 
-	return out
+	return out, nil
 }
@@ -71,7 +71,10 @@ var Z = exec.Cmd{}`,
 		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
-			got := PackageUsages(tc.src)
+			got, err := PackageUsages(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
 
 			if diff := cmp.Diff(got, tc.want); diff != "" {
 				t.Fatalf("expected %v, got %v\n\t%s", tc.want, got, diff)
@@ -79,3 +82,10 @@ var Z = exec.Cmd{}`,
 		})
 	}
 }
+
+func TestPackageUsagesParseError(t *testing.T) {
+	_, err := PackageUsages("package main\nfunc {")
+	if _, ok := err.(*ParseError); !ok {
+		t.Fatalf("got error %v, want a *ParseError", err)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/jadekler/lean/internal"
 )
@@ -11,6 +12,9 @@ func main() {
 	// github.com/getlantern/idletiming -> github.com/aristanetworks/goarista@v0.0.0-20200131140622-c6473e3ed183
 	from := "github.com/getlantern/idletiming"
 	to := "github.com/aristanetworks/goarista@v0.0.0-20200131140622-c6473e3ed183"
-	c := p.ModuleUsagesForModule(from, to)
+	c, err := p.ModuleUsagesForModule(from, to)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println(c)
 }
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModuleNotFoundError is returned when a module isn't on the file system, and
+// couldn't be downloaded.
+type ModuleNotFoundError struct {
+	Module string
+
+	// Err is why the module couldn't be downloaded, if downloading it was
+	// tried.
+	Err error
+}
+
+func (e *ModuleNotFoundError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("could not find module %s on file system. try `go get %s`?", e.Module, e.Module)
+	}
+	return fmt.Sprintf("could not find module %s: %v", e.Module, e.Err)
+}
+
+func (e *ModuleNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// CommandError is returned when a command that lean runs fails.
+type CommandError struct {
+	Dir  string
+	Args []string
+
+	// Stderr is what the command printed to stderr, if it was captured.
+	Stderr string
+
+	Err error
+}
+
+func (e *CommandError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("failed to run `cd %s && %s`: %v", e.Dir, strings.Join(e.Args, " "), e.Err)
+	}
+	return fmt.Sprintf("failed to run `cd %s && %s`:\n%s\n%v", e.Dir, strings.Join(e.Args, " "), e.Stderr, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is returned when Go source can't be parsed.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error parsing go source: %v", e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ModulePathError is returned when a module path isn't of the form
+// path@version.
+type ModulePathError struct {
+	ModulePath string
+}
+
+func (e *ModulePathError) Error() string {
+	return fmt.Sprintf("couldn't figure out module name from module path %s", e.ModulePath)
+}
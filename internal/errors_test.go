@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestModuleNameFromModulePath(t *testing.T) {
+	got, err := moduleNameFromModulePath("golang.org/x/text@v0.3.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "golang.org/x/text"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if _, err := moduleNameFromModulePath("golang.org/x/text"); err == nil {
+		t.Error("got no error for a module path without a version")
+	} else if _, ok := err.(*ModulePathError); !ok {
+		t.Errorf("got error %v, want a *ModulePathError", err)
+	}
+}
+
+func TestModuleNotFoundErrorUnwrap(t *testing.T) {
+	exitErr := &exec.ExitError{}
+	err := error(&ModuleNotFoundError{
+		Module: "example.com/m@v1.0.0",
+		Err:    &CommandError{Dir: "/tmp", Args: []string{"go", "get", "-d", "example.com/m@v1.0.0"}, Err: exitErr},
+	})
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("got error %v, want it to wrap a *CommandError", err)
+	}
+	var gotExitErr *exec.ExitError
+	if !errors.As(err, &gotExitErr) || gotExitErr != exitErr {
+		t.Errorf("got error %v, want it to wrap the command's *exec.ExitError", err)
+	}
+}
@@ -64,14 +64,14 @@ func readableModCache(moduleRootPath string) (readableCopy string, cleanup func(
 // everything file-by-file, since there's no recursive Chmod; etc).
 func removeContents(dir string) error {
 	chmodCMD := exec.Command("chmod", "-R", "0777", dir)
-	chmodCMD.Stdout = os.Stdout
+	chmodCMD.Stdout = os.Stderr
 	chmodCMD.Stderr = os.Stderr
 	if err := chmodCMD.Run(); err != nil {
 		return err
 	}
 
 	rmCMD := exec.Command("rm", "-rf", dir)
-	rmCMD.Stdout = os.Stdout
+	rmCMD.Stdout = os.Stderr
 	rmCMD.Stderr = os.Stderr
 	return rmCMD.Run()
 }
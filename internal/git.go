@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commit is a git commit.
+type Commit struct {
+	Hash    string
+	Time    time.Time
+	Subject string
+}
+
+// GitCommits returns the commits that changed the given files in dir's git
+// repository, oldest first. The files are relative to dir.
+func GitCommits(dir string, files ...string) ([]Commit, error) {
+	args := append([]string{"log", "--reverse", "--format=%H %ct %s", "--"}, files...)
+	out, err := gitCommand(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, l := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if l == "" {
+			continue
+		}
+		parts := strings.SplitN(l, " ", 3)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("unexpected git log line: %s", l)
+		}
+		secs, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git log line: %s", l)
+		}
+		c := Commit{Hash: parts[0], Time: time.Unix(secs, 0).UTC()}
+		if len(parts) == 3 {
+			c.Subject = parts[2]
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// GitShow returns the contents of file, relative to dir, at the given commit.
+// It returns nil, nil if the file didn't exist at that commit.
+func GitShow(dir, hash, file string) ([]byte, error) {
+	// ./ makes the path relative to dir rather than to the repository root.
+	if _, err := gitCommand(dir, "cat-file", "-e", hash+":./"+file); err != nil {
+		return nil, nil
+	}
+	return gitCommand(dir, "show", hash+":./"+file)
+}
+
+func gitCommand(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Dir = dir
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run `cd %s && git %s`:\n%s\n%v", dir, strings.Join(args, " "), stderr.String(), err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// OfflineModGraph runs `go mod graph` for a module with the given go.mod and
+// go.sum, without touching the network: every module it needs must already be
+// in the module cache. goSum may be nil.
+func OfflineModGraph(goMod, goSum []byte) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "lean-history")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), goMod, 0644); err != nil {
+		return nil, err
+	}
+	if goSum != nil {
+		if err := ioutil.WriteFile(filepath.Join(dir, "go.sum"), goSum, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := exec.Command("go", "mod", "graph")
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOPROXY=off", "GOFLAGS=-mod=mod", "GOTOOLCHAIN=local")
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run `go mod graph` offline:\n%s\n%v", stderr.String(), err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Module is a module as described by `go list -m -json`.
+type Module struct {
+	Path       string  `json:"Path"`
+	Version    string  `json:"Version"`
+	Replace    *Module `json:"Replace"`
+	Main       bool    `json:"Main"`
+	Indirect   bool    `json:"Indirect"`
+	Dir        string  `json:"Dir"`
+	GoMod      string  `json:"GoMod"`
+	GoVersion  string  `json:"GoVersion"`
+	Deprecated string  `json:"Deprecated"`
+}
+
+// Label returns the module's label in `go mod graph` output: its path, plus
+// its version unless it's the main module.
+func (m *Module) Label() string {
+	if m.Main || m.Version == "" {
+		return m.Path
+	}
+	return m.Path + "@" + m.Version
+}
+
+// DecodeModules decodes the output of `go list -m -json all`, which is a
+// stream of JSON objects rather than a list.
+func DecodeModules(r io.Reader) ([]*Module, error) {
+	var modules []*Module
+	dec := json.NewDecoder(r)
+	for {
+		m := &Module{}
+		err := dec.Decode(m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decoding go list -m -json output: %s", err)
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
+
+// ModGraph runs `go mod graph` in dir, and returns its output.
+func ModGraph(dir string) ([]byte, error) {
+	return goCommand(dir, "mod", "graph")
+}
+
+// ListModules runs `go list -m -u -json all` in dir, and returns the modules
+// it lists. -u, which looks up deprecations and so needs the network, is left
+// out offline: see SetOffline.
+func ListModules(dir string) ([]*Module, error) {
+	args := []string{"list", "-m", "-u", "-json", "all"}
+	if isOffline() {
+		args = []string{"list", "-m", "-json", "all"}
+	}
+	out, err := goCommand(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeModules(bytes.NewReader(out))
+}
+
+func goCommand(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("go", args...)
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Dir = dir
+	cmd.Env = goEnv()
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run `cd %s && go %s`:\n%s\n%v", dir, strings.Join(args, " "), stderr.String(), err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// moduleDirsMu protects moduleDirs.
+var moduleDirsMu = sync.Mutex{}
+
+// moduleDirs maps module labels (see Module.Label) to where the module is on
+// the file system. It is checked before searching for a module.
+var moduleDirs = make(map[string]string)
+
+// mainDirs are the directories of the registered main modules.
+var mainDirs []string
+
+// RegisterModules records where the given modules are on the file system, so
+// that they're found there rather than by searching. In particular, this makes
+// main modules resolve to the directory their graph came from, rather than to
+// the current directory.
+func RegisterModules(modules []*Module) {
+	moduleDirsMu.Lock()
+	defer moduleDirsMu.Unlock()
+
+	for _, m := range modules {
+		if m.Dir != "" {
+			moduleDirs[m.Label()] = m.Dir
+			if m.Main {
+				mainDirs = append(mainDirs, m.Dir)
+			}
+		}
+	}
+}
+
+// registeredModuleDir returns where the module was registered to be, or empty
+// string if it wasn't.
+func registeredModuleDir(module string) string {
+	moduleDirsMu.Lock()
+	defer moduleDirsMu.Unlock()
+
+	return moduleDirs[module]
+}
+
+// registeredMainDirs returns the directories of the registered main modules.
+func registeredMainDirs() []string {
+	moduleDirsMu.Lock()
+	defer moduleDirsMu.Unlock()
+
+	return append([]string(nil), mainDirs...)
+}
+
+// hasRegisteredModules returns whether any modules have been registered.
+func hasRegisteredModules() bool {
+	moduleDirsMu.Lock()
+	defer moduleDirsMu.Unlock()
+
+	return len(moduleDirs) > 0
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeModules(t *testing.T) {
+	in := `{
+	"Path": "github.com/foo/main",
+	"Main": true,
+	"Dir": "/src/main",
+	"GoMod": "/src/main/go.mod",
+	"GoVersion": "1.13"
+}
+{
+	"Path": "github.com/foo/bar",
+	"Version": "v1.2.3",
+	"Indirect": true,
+	"Dir": "/gopath/pkg/mod/github.com/foo/bar@v1.2.3"
+}
+`
+	got, err := DecodeModules(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Module{
+		{Path: "github.com/foo/main", Main: true, Dir: "/src/main", GoMod: "/src/main/go.mod", GoVersion: "1.13"},
+		{Path: "github.com/foo/bar", Version: "v1.2.3", Indirect: true, Dir: "/gopath/pkg/mod/github.com/foo/bar@v1.2.3"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Fatalf("got different modules (extraneous -, missing +):\n%s", diff)
+	}
+
+	var labels []string
+	for _, m := range got {
+		labels = append(labels, m.Label())
+	}
+	if diff := cmp.Diff(labels, []string{"github.com/foo/main", "github.com/foo/bar@v1.2.3"}); diff != "" {
+		t.Errorf("got different labels (extraneous -, missing +):\n%s", diff)
+	}
+}
@@ -18,15 +18,39 @@ type ModuleSizer struct{}
 
 // ModuleSize returns the size of the module on the OS. It is non-cumulative.
 //
-// If the module can not be found, it returns -1,nil.
+// If the module can't be downloaded, or still can't be found after it is, it
+// returns -1 and a *ModuleNotFoundError.
 //
 // Other errors are returned as -1,err.
 func (ms *ModuleSizer) ModuleSize(module string) (int64, error) {
-	path := attemptToFindModuleOnFS(module)
+	path, err := attemptToFindModuleOnFS(module)
+	if err != nil {
+		return -1, err
+	}
+	if path == "" {
+		return -1, &ModuleNotFoundError{Module: module}
+	}
+	return dirSize(path)
+}
+
+// OfflineModuleSizer is a ModuleSizer that never downloads anything. Modules
+// that weren't registered with RegisterModules, and aren't vendored or in the
+// module cache, are of unknown size.
+type OfflineModuleSizer struct{}
+
+// ModuleSize returns the size of the module on the OS. It is non-cumulative.
+//
+// If the module can not be found, it returns -1,nil.
+func (*OfflineModuleSizer) ModuleSize(module string) (int64, error) {
+	path := findOffline(module)
 	if path == "" {
 		return -1, nil
 	}
+	return dirSize(path)
+}
 
+// dirSize returns the total size of the files in dir.
+func dirSize(path string) (int64, error) {
 	var size int64
 	if err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -48,16 +72,16 @@ func (ms *ModuleSizer) ModuleSize(module string) (int64, error) {
 // For example, something like golang.org/x/text@v0.3.0 becomes
 // golang.org/x/text.
 //
-// It panics if it encounters an error or can't figure out the module name.
+// It returns a *ModulePathError if it can't figure out the module name.
 //
 // TODO: It'd be much nicer if there was a library that figured this out
 // holistically, or perhaps by querying module proxy.
-func moduleNameFromModulePath(modulePath string) string {
+func moduleNameFromModulePath(modulePath string) (string, error) {
 	parts := strings.Split(modulePath, "@")
 	if len(parts) != 2 {
-		panic(fmt.Sprintf("couldn't figure out module name from module path %s", modulePath))
+		return "", &ModulePathError{ModulePath: modulePath}
 	}
-	return parts[0]
+	return parts[0], nil
 }
 
 // moduleFiles finds all the files of a module (given the module's root path).
@@ -84,6 +108,7 @@ func moduleFiles(moduleRootPath string) (moduleFiles []string, cleanup func(), _
 	listCMD.Stdout = &stdout
 	listCMD.Stderr = &stderr
 	listCMD.Dir = tmpWorkDir
+	listCMD.Env = goEnv()
 	if err := listCMD.Run(); err != nil {
 		return nil, nil, fmt.Errorf("failed to run `cd %s && go list -json ./...`:\n%s\n%v", tmpWorkDir, stderr.String(), err)
 	}
@@ -121,19 +146,36 @@ var versionRegexp = regexp.MustCompile("(.+)/v[0-9]+")
 // attemptToFindModuleOnFS attempts to find module on the fs. If it's found, it
 // returns the filepath. If not, it returns empty string.
 //
-// As part of its attempts, it will `go get` missing modules.
-func attemptToFindModuleOnFS(module string) string {
-	module = replaceCapitalLetters(module)
-
-	if loc := findOnFS(module); loc != "" {
-		return loc
+// Modules registered with RegisterModules are found where they were
+// registered, and vendored modules in the vendor directory.
+//
+// As part of its attempts, it will `go get` missing modules, unless lean is
+// offline. If that fails, or lean is offline, it returns a
+// *ModuleNotFoundError.
+func attemptToFindModuleOnFS(module string) (string, error) {
+	if dir := findOffline(module); dir != "" {
+		return dir, nil
 	}
 
-	if err := goGet(module); err != nil {
-		panic(err)
+	escaped := replaceCapitalLetters(module)
+
+	loc, err := findOnFS(escaped)
+	if loc != "" {
+		return loc, nil
+	}
+	// Offline, there's nothing more to try, so a module that can't be found,
+	// even for want of a current module to look in, is missing.
+	if isOffline() {
+		return "", &ModuleNotFoundError{Module: module, Err: ErrOffline}
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := goGet(escaped); err != nil {
+		return "", &ModuleNotFoundError{Module: module, Err: err}
 	}
 
-	return findOnFS(module)
+	return findOnFS(escaped)
 }
 
 // findOnFS checks whether the given module is in,
@@ -142,29 +184,45 @@ func attemptToFindModuleOnFS(module string) string {
 //
 // If it finds the module at one of the above, it returns the filepath. If not,
 // it returns empty.
-//
-// TODO: It should try $CURDIR/vendor, too.
-func findOnFS(module string) string {
-	gopathAttempt := filepath.Join(build.Default.GOPATH, "pkg", "mod", module)
-	if _, err := os.Stat(gopathAttempt); err == nil {
-		return gopathAttempt
+func findOnFS(module string) (string, error) {
+	if loc := findInModuleCache(module); loc != "" {
+		return loc, nil
+	}
+
+	// If lean was pointed at module directories, the current directory has
+	// nothing to do with the graph.
+	if hasRegisteredModules() {
+		return "", nil
 	}
 
 	curdir, err := os.Getwd()
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+	curModuleRootName, curModuleRootPath, err := getModuleRoot(curdir)
+	if err != nil {
+		return "", err
 	}
-	curModuleRootName, curModuleRootPath := getModuleRoot(curdir)
 	if curModuleRootName == module {
-		return curModuleRootPath
+		return curModuleRootPath, nil
 	}
 
-	// TODO: try vendor
+	return "", nil
+}
 
+// findInModuleCache returns where the module is in $GOPATH/pkg/mod, or empty
+// string if it isn't there.
+func findInModuleCache(module string) string {
+	gopathAttempt := filepath.Join(build.Default.GOPATH, "pkg", "mod", module)
+	if _, err := os.Stat(gopathAttempt); err == nil {
+		return gopathAttempt
+	}
 	return ""
 }
 
-func getModuleRoot(dir string) (name, root string) {
+// getModuleRoot returns the path and root directory of the module that dir is
+// in. If `go list` fails, it returns a *CommandError.
+func getModuleRoot(dir string) (name, root string, _ error) {
 	type Module struct {
 		Path string `json:"Path"`
 		Dir  string `json:"Dir"`
@@ -182,15 +240,16 @@ func getModuleRoot(dir string) (name, root string) {
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	cmd.Dir = dir
+	cmd.Env = goEnv()
 	if err := cmd.Run(); err != nil {
-		panic(fmt.Errorf("failed to run `cd %s && go list -json ./...`:\n%s\n%v", dir, stderr.String(), err))
+		return "", "", &CommandError{Dir: dir, Args: cmd.Args, Stderr: stderr.String(), Err: err}
 	}
 
 	if err := json.NewDecoder(&stdout).Decode(&decoded); err != nil {
-		panic(err)
+		return "", "", fmt.Errorf("error decoding go list -json output: %v", err)
 	}
 
-	return decoded.Mod.Path, decoded.Mod.Dir
+	return decoded.Mod.Path, decoded.Mod.Dir, nil
 }
 
 // goGet caches the given module.
@@ -200,7 +259,7 @@ func goGet(moduleName string) error {
 
 	// Get the module, causing it to be cached.
 	cmd := exec.Command("go", "get", "-d", moduleName)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = os.Stderr
 	cmd.Stderr = os.Stderr
 	cmd.Dir = dir
 
@@ -210,7 +269,7 @@ func goGet(moduleName string) error {
 	// So that we don't get "path@version can't be used in GOPATH mode" errors.
 	cmd.Env = append(cmd.Env, "GO111MODULE=on")
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("error running `go get %s`: %s", moduleName, err)
+		return &CommandError{Dir: dir, Args: cmd.Args, Err: err}
 	}
 
 	return nil
@@ -221,10 +280,10 @@ func goGet(moduleName string) error {
 //
 // TODO(deklerk): We need to copy the $GOPATH/mod/pkg to /tmp first, since
 // $GOPATH is protected by -mod=readonly.
-func indirectModules(moduleRootPath string) []string {
+func indirectModules(moduleRootPath string) ([]string, error) {
 	tmpWorkDir, cleanup, err := readableModCache(moduleRootPath)
 	if err != nil {
-		panic(fmt.Errorf("failed to create a readable copy of %s: %s", moduleRootPath, err))
+		return nil, fmt.Errorf("failed to create a readable copy of %s: %s", moduleRootPath, err)
 	}
 	defer cleanup()
 
@@ -240,9 +299,10 @@ func indirectModules(moduleRootPath string) []string {
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	cmd.Dir = tmpWorkDir
+	cmd.Env = goEnv()
 
 	if err := cmd.Run(); err != nil {
-		panic(fmt.Errorf(`failed to run $(cd %s && go list -f '{"name":"{{.Path}}","indirect":{{.Indirect}}}' -m all :\n%s\n%v}`, tmpWorkDir, stderr.String(), err))
+		return nil, &CommandError{Dir: tmpWorkDir, Args: cmd.Args, Stderr: stderr.String(), Err: err}
 	}
 
 	var modules []Module
@@ -253,12 +313,12 @@ func indirectModules(moduleRootPath string) []string {
 			if err == io.EOF {
 				break
 			}
-			panic(err)
+			return nil, err
 		}
 		module := Module{}
 		buf := bytes.NewBufferString(line)
 		if err := json.NewDecoder(buf).Decode(&module); err != nil {
-			panic(err)
+			return nil, fmt.Errorf("error decoding go list -m output: %v", err)
 		}
 		modules = append(modules, module)
 	}
@@ -270,5 +330,5 @@ func indirectModules(moduleRootPath string) []string {
 		}
 	}
 
-	return indirect
+	return indirect, nil
 }
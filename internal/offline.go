@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrOffline is wrapped by the errors for modules that weren't downloaded
+// because lean is offline. See SetOffline.
+var ErrOffline = errors.New("not downloaded in offline mode")
+
+// offlineMu protects offline.
+var offlineMu = sync.Mutex{}
+
+// offline is whether lean must not download anything.
+var offline bool
+
+// SetOffline makes lean find modules only where they were registered (see
+// RegisterModules), in the main modules' vendor directories, and in the module
+// cache, rather than downloading the ones that are missing. The go commands
+// that lean runs aren't allowed to download anything either, so that they
+// fail rather than hang without a network.
+func SetOffline(b bool) {
+	offlineMu.Lock()
+	defer offlineMu.Unlock()
+
+	offline = b
+}
+
+func isOffline() bool {
+	offlineMu.Lock()
+	defer offlineMu.Unlock()
+
+	return offline
+}
+
+// goEnv returns the environment to run go commands in.
+func goEnv() []string {
+	env := os.Environ()
+	if isOffline() {
+		env = append(env, "GOPROXY=off", "GOTOOLCHAIN=local")
+	}
+	return env
+}
+
+// findOffline returns where the module is without downloading it, or empty
+// string if it can't be found that way.
+func findOffline(module string) string {
+	if dir := registeredModuleDir(module); dir != "" {
+		return dir
+	}
+	if dir := findInVendor(module); dir != "" {
+		return dir
+	}
+	return findInModuleCache(replaceCapitalLetters(module))
+}
+
+// findInVendor returns where the module is vendored by a main module, or empty
+// string if it isn't. The main modules are the registered ones, or else the
+// current directory.
+//
+// Only the packages that the main module uses are vendored, so the directory
+// may be smaller than the module.
+func findInVendor(module string) string {
+	parts := strings.Split(module, "@")
+	if len(parts) != 2 {
+		return ""
+	}
+	path, version := parts[0], parts[1]
+
+	roots := registeredMainDirs()
+	if len(roots) == 0 {
+		curdir, err := os.Getwd()
+		if err != nil {
+			return ""
+		}
+		roots = []string{curdir}
+	}
+	for _, root := range roots {
+		vendor := filepath.Join(root, "vendor")
+		if !vendorsModule(filepath.Join(vendor, "modules.txt"), path, version) {
+			continue
+		}
+		dir := filepath.Join(vendor, filepath.FromSlash(path))
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
+// vendorsModule returns whether the vendor/modules.txt file lists the module
+// at the given version.
+func vendorsModule(modulesTxt, path, version string) bool {
+	f, err := os.Open(modulesTxt)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	// Each vendored module has a line like
+	//   # example.com/m v1.0.0
+	// or, if it's replaced,
+	//   # example.com/m v1.0.0 => ../m
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[0] == "#" && fields[1] == path && fields[2] == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindInVendor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lean-vendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	modulesTxt := "# example.com/a v1.0.0\n## explicit\nexample.com/a\n# example.com/b v1.2.0 => ../b\nexample.com/b/pkg\n"
+	if err := os.MkdirAll(filepath.Join(dir, "vendor", "example.com", "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "vendor", "example.com", "b", "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "vendor", "modules.txt"), []byte(modulesTxt), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	curdir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(curdir)
+
+	for _, tc := range []struct {
+		module string
+		want   string
+	}{
+		{module: "example.com/a@v1.0.0", want: filepath.Join(dir, "vendor", "example.com", "a")},
+		{module: "example.com/b@v1.2.0", want: filepath.Join(dir, "vendor", "example.com", "b")},
+		// Other versions aren't vendored.
+		{module: "example.com/a@v1.1.0", want: ""},
+		{module: "example.com/c@v1.0.0", want: ""},
+		{module: "example.com/a", want: ""},
+	} {
+		if got := findInVendor(tc.module); got != tc.want {
+			t.Errorf("findInVendor(%s): got %q, want %q", tc.module, got, tc.want)
+		}
+	}
+}
+
+func TestAttemptToFindModuleOnFSOffline(t *testing.T) {
+	SetOffline(true)
+	defer SetOffline(false)
+
+	// Outside of any module, so that the current module can't be listed
+	// either.
+	dir, err := ioutil.TempDir("", "lean-offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	curdir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(curdir)
+
+	_, err = attemptToFindModuleOnFS("example.invalid/missing@v1.0.0")
+	var notFound *ModuleNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("got error %v, want a *ModuleNotFoundError", err)
+	}
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("got error %v, want it to wrap ErrOffline", err)
+	}
+	if want := "example.invalid/missing@v1.0.0"; notFound.Module != want {
+		t.Errorf("got module %s, want %s", notFound.Module, want)
+	}
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Package is a package as described by `go list -json`.
+type Package struct {
+	ImportPath string   `json:"ImportPath"`
+	Dir        string   `json:"Dir"`
+	Standard   bool     `json:"Standard"`
+	DepOnly    bool     `json:"DepOnly"`
+	Module     *Module  `json:"Module"`
+	GoFiles    []string `json:"GoFiles"`
+	CgoFiles   []string `json:"CgoFiles"`
+	Imports    []string `json:"Imports"`
+}
+
+// files returns the paths of the package's non-test go files.
+func (p *Package) files() []string {
+	var out []string
+	for _, f := range append(append([]string{}, p.GoFiles...), p.CgoFiles...) {
+		out = append(out, filepath.Join(p.Dir, f))
+	}
+	return out
+}
+
+// DecodePackages decodes the output of `go list -json`, which is a stream of
+// JSON objects rather than a list.
+func DecodePackages(r io.Reader) ([]*Package, error) {
+	var packages []*Package
+	dec := json.NewDecoder(r)
+	for {
+		p := &Package{}
+		err := dec.Decode(p)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decoding go list -json output: %s", err)
+		}
+		packages = append(packages, p)
+	}
+	return packages, nil
+}
+
+// ListPackages runs `go list -deps -json ./...` in dir, and returns the
+// packages it lists.
+func ListPackages(dir string) ([]*Package, error) {
+	out, err := goCommand(dir, "list", "-deps", "-json", "./...")
+	if err != nil {
+		return nil, err
+	}
+	return DecodePackages(bytes.NewReader(out))
+}
+
+type PackageAnalyzer struct{}
+
+// PackageSize returns the total size of the package's non-test go files.
+//
+// If the package's files can not be found, it returns -1,nil.
+func (*PackageAnalyzer) PackageSize(p *Package) (int64, error) {
+	var size int64
+	for _, f := range p.files() {
+		info, err := os.Stat(f)
+		if os.IsNotExist(err) {
+			return -1, nil
+		}
+		if err != nil {
+			return -1, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// ImportUsages returns the number of times the package's non-test go files use
+// each package that they import.
+func (*PackageAnalyzer) ImportUsages(p *Package) (map[string]int, error) {
+	out := make(map[string]int)
+	for _, f := range p.files() {
+		src, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		usages, err := PackageUsages(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		for k, v := range usages {
+			out[k] += v
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodePackages(t *testing.T) {
+	in := `{
+	"Dir": "/src/main/cmd",
+	"ImportPath": "github.com/foo/main/cmd",
+	"Module": {"Path": "github.com/foo/main", "Main": true},
+	"GoFiles": ["main.go"],
+	"Imports": ["fmt", "github.com/foo/bar"]
+}
+{
+	"ImportPath": "fmt",
+	"Standard": true,
+	"DepOnly": true
+}
+`
+	got, err := DecodePackages(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Package{
+		{
+			ImportPath: "github.com/foo/main/cmd",
+			Dir:        "/src/main/cmd",
+			Module:     &Module{Path: "github.com/foo/main", Main: true},
+			GoFiles:    []string{"main.go"},
+			Imports:    []string{"fmt", "github.com/foo/bar"},
+		},
+		{ImportPath: "fmt", Standard: true, DepOnly: true},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Fatalf("got different packages (extraneous -, missing +):\n%s", diff)
+	}
+}
@@ -11,16 +11,57 @@
 //
 //	go mod graph | lean
 //	go mod graph | digraph transpose | lean
+//	lean ./path/to/module [./path/to/another/module...]
+//	go mod graph | lean recommend <module>
+//	go mod graph | lean suggest [-n N]
+//	go mod graph | lean why [-max N] <module>
+//	go mod graph | lean report [-format text|json|csv]
+//	lean -load session.json report -html out.html
+//	go mod graph | lean export [-format dot|graphml|mermaid|json]
+//	lean -load session.json export -cuts
+//	go mod graph | lean check [-policy .lean-policy.json] [-baseline] [-update-baseline]
+//	lean diff [-serve] before.txt after.txt
+//	lean history [-serve] [module-dir]
+//	go mod graph | lean -modules <(go list -m -json all)
+//	go list -deps -json ./... | lean -packages
+//	lean -packages ./path/to/module [./path/to/another/module...]
+//	go mod graph | lean -save session.json
+//	lean -load session.json
+//
+// -packages graphs the packages that a module's packages import, rather than
+// its module requirements. Each package is linked to the module it belongs to.
+//
+// -save writes the analyzed graph and the cuts made so far to a file, which
+// -load resumes from instead of reading stdin. -save also names the file that
+// the UI's Save button writes to.
+//
+// A module directory named like a command must be given as a path, such as
+// ./report.
+//
+// With no command, lean serves the graph on :3000. Commands instead print
+// their answer and exit:
+//
+//	recommend	Print the cheapest set of edges to cut to remove a module.
+//	suggest		Print the edges whose cut prunes the most for the least usage.
+//	why		Print the paths by which the root depends on a module.
+//	report		Print every edge, and what cutting it would prune.
+//	export		Print the graph as DOT, GraphML, Mermaid or JSON.
+//	check		Check the graph against a policy, or for what's new since a baseline.
+//	diff		Print how one go mod graph output differs from another.
+//	history		Print how a module's graph grew over its git history.
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -37,20 +78,65 @@ type ReplaceableModuleSizer interface {
 // Only exists to make the ast parser pluggable, since we don't want our tests
 // to cause file system reads.
 type ReplaceableASTParser interface {
-	ModuleUsagesForModule(from, to string) int
+	ModuleUsagesForModule(from, to string) (int, error)
+}
+
+// Only exists to make the package analyzer pluggable, since we don't want our
+// tests to cause file system reads.
+type ReplaceablePackageAnalyzer interface {
+	PackageSize(*internal.Package) (int64, error)
+	ImportUsages(*internal.Package) (map[string]int, error)
 }
 
 var (
-	mu            sync.Mutex
-	originalGraph *graph
-	userGraph     *graph
-	shoppingCart                         = make(map[string]map[string]struct{})
-	moduleSizer   ReplaceableModuleSizer = &internal.ModuleSizer{}
-	astParser     ReplaceableASTParser   = &internal.ASTParser{}
+	mu              sync.Mutex
+	originalGraph   *graph
+	moduleSizer     ReplaceableModuleSizer     = &internal.ModuleSizer{}
+	astParser       ReplaceableASTParser       = &internal.ASTParser{}
+	packageAnalyzer ReplaceablePackageAnalyzer = &internal.PackageAnalyzer{}
+
+	// scenarios are the named sets of cuts being compared, and
+	// currentScenario is the one being worked on.
+	scenarios       = make(map[string]*scenario)
+	currentScenario string
+
+	// userGraph, shoppingCart and opHistory belong to the current scenario.
+	// See useScenario.
+	userGraph    *graph
+	shoppingCart *cart
+	opHistory    *history
+
+	// diffBase is the graph that originalGraph is compared against, when
+	// serving a diff. See diffCommand.
+	diffBase *graph
+
+	// growth is the history of the graph being served, if it's being served
+	// with its history. See historyCommand.
+	growth *graphHistory
+
+	// analysis is the progress of the graph's sizes and usages, if they're
+	// being found in the background. See startGraph.
+	analysis *analysisFeed
 )
 
+// moduleDirs are the module directories given on the command line, if any.
+var moduleDirs []string
+
+var (
+	modulesPath  = flag.String("modules", "", "file containing `go list -m -json all` output for the graph read from stdin")
+	packagesMode = flag.Bool("packages", false, "graph packages rather than modules, from `go list -deps -json ./...` output on stdin, or run in each module directory")
+	loadPath     = flag.String("load", "", "resume the session saved in this file instead of reading stdin")
+	savePath     = flag.String("save", "", "save the session to this file once loaded, and whenever the UI's Save button is clicked")
+	offline      = flag.Bool("offline", false, "never download modules: find them only in the module cache, vendor directories and replacements, and report the rest as missing")
+)
+
+// defaultSessionPath is where the UI's Save button writes the session if
+// neither -save nor -load was given.
+const defaultSessionPath = "lean-session.json"
+
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: go mod graph | lean")
+	fmt.Fprintf(os.Stderr, "Usage: go mod graph | lean [-save file] [command] [args]\n       lean [-save file] module-dir...\n       lean -load file [command] [args]\n")
+	flag.PrintDefaults()
 	os.Exit(2)
 }
 
@@ -60,19 +146,266 @@ func main() {
 
 	flag.Usage = usage
 	flag.Parse()
-	if flag.NArg() != 0 {
+	internal.SetOffline(*offline)
+
+	run, args := parseArgs(flag.Args())
+	if run != nil {
+		if err := run(args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *loadPath != "" && len(args) != 0 {
 		usage()
 	}
+	moduleDirs = args
+	load := loadGraph
+	if *loadPath == "" && !*packagesMode {
+		// Sizing and analyzing a module graph can take minutes, so it's served
+		// while that happens.
+		load = startGraph
+	}
+	if err := load(); err != nil {
+		log.Fatal(err)
+	}
+	serve()
+}
 
+// parseArgs returns the command named by the first argument, and the
+// arguments following it. If the first argument isn't a command, the
+// arguments are module directories to serve, or none to serve stdin, and the
+// command is nil.
+//
+// Commands win: a module directory named like one, such as report, must be
+// given as ./report.
+func parseArgs(args []string) (func([]string) error, []string) {
+	if len(args) == 0 {
+		return nil, args
+	}
+	if run, ok := commands[args[0]]; ok {
+		return run, args[1:]
+	}
+	return nil, args
+}
+
+// loadGraph reads the graph from stdin, or the session from -load, into
+// originalGraph and the scenarios. If -save was given, the session is then
+// saved.
+func loadGraph() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if *loadPath != "" {
+		if err := loadSession(*loadPath); err != nil {
+			return err
+		}
+	} else {
+		var g *graph
+		if *packagesMode {
+			packages, err := packageInput()
+			if err != nil {
+				return err
+			}
+			if g, err = newPackageGraph(packages); err != nil {
+				return err
+			}
+		} else {
+			r, modules, err := graphInput()
+			if err != nil {
+				return err
+			}
+			if g, err = newGraph(r); err != nil {
+				return err
+			}
+			g.annotateModules(modules)
+		}
+		logAnalysisSummary(g)
+		if err := useGraph(g); err != nil {
+			return err
+		}
+	}
+
+	if *savePath != "" {
+		return saveSession(*savePath)
+	}
+	return nil
+}
+
+// startGraph reads the graph from stdin as loadGraph does, but sizes its
+// vertices and analyzes its edges in the background, so that it can be served
+// straight away. analysis reports how they're getting on. If -save was given,
+// the session is saved once they're done.
+func startGraph() error {
 	mu.Lock()
-	var err error
-	originalGraph, err = newGraph(os.Stdin)
+	defer mu.Unlock()
+
+	r, modules, err := graphInput()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	g, err := parseGraph(r)
+	if err != nil {
+		return err
+	}
+	g.annotateModules(modules)
+	if err := useGraph(g); err != nil {
+		return err
+	}
+
+	analysis = newAnalysisFeed()
+	go func() {
+		analyzeGraph(g, moduleSizer, astParser, func(ev analysisEvent) {
+			mu.Lock()
+			applyAnalysisLocked(ev)
+			mu.Unlock()
+			analysis.publish(ev)
+		})
+		analysis.finish()
+		logAnalysisSummary(g)
+		if *savePath != "" {
+			mu.Lock()
+			defer mu.Unlock()
+			if err := saveSession(*savePath); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+	return nil
+}
+
+// applyAnalysisLocked copies a result of the background analysis into the
+// original graph, and into every scenario's graph and cut edges.
+//
+// mu must be held.
+func applyAnalysisLocked(ev analysisEvent) {
+	originalGraph.applyAnalysis(ev)
+	for _, s := range scenarios {
+		s.graph.applyAnalysis(ev)
+		s.cart.applyAnalysis(ev)
+	}
+}
+
+// graphInput returns the `go mod graph` output to build the graph from, and
+// the `go list -m -json all` output describing its modules, if any. They're
+// stdin and the -modules file, unless moduleDirs were given, in which case lean
+// runs both commands in each of them.
+//
+// When there are several modules, their graphs are joined under
+// workspaceRoot.
+func graphInput() (io.Reader, []*internal.Module, error) {
+	if len(moduleDirs) == 0 {
+		if *modulesPath == "" {
+			return os.Stdin, nil, nil
+		}
+		f, err := os.Open(*modulesPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		modules, err := internal.DecodeModules(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		internal.RegisterModules(modules)
+		return os.Stdin, modules, nil
 	}
-	userGraph = originalGraph.copy()
-	mu.Unlock()
 
+	var roots, graphs bytes.Buffer
+	var allModules []*internal.Module
+	for _, dir := range moduleDirs {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Record where each module is, so that they're analyzed in dir
+		// rather than wherever lean happens to be run.
+		modules, err := internal.ListModules(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		internal.RegisterModules(modules)
+		allModules = append(allModules, modules...)
+
+		out, err := internal.ModGraph(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		graphs.Write(out)
+		for _, m := range modules {
+			if m.Main {
+				fmt.Fprintf(&roots, "%s %s\n", workspaceRoot, m.Label())
+			}
+		}
+	}
+	if len(moduleDirs) == 1 {
+		return &graphs, allModules, nil
+	}
+	return io.MultiReader(&roots, &graphs), allModules, nil
+}
+
+// useGraph makes g the original graph, with a single default scenario.
+//
+// mu must be held.
+func useGraph(g *graph) error {
+	originalGraph = g
+	scenarios = map[string]*scenario{defaultScenario: newScenario(g)}
+	return useScenario(defaultScenario)
+}
+
+// packageInput returns the `go list -deps -json ./...` output to build a
+// package graph from. It's stdin, unless moduleDirs were given, in which case
+// lean runs `go list` in each of them. Packages listed by several of them are
+// only returned once.
+func packageInput() ([]*internal.Package, error) {
+	if len(moduleDirs) == 0 {
+		return internal.DecodePackages(os.Stdin)
+	}
+
+	var out []*internal.Package
+	seen := make(map[string]bool)
+	for _, dir := range moduleDirs {
+		packages, err := internal.ListPackages(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range packages {
+			if !seen[p.ImportPath] {
+				seen[p.ImportPath] = true
+				out = append(out, p)
+			}
+		}
+	}
+	return out, nil
+}
+
+// sessionPath is where the UI's Save button writes the session.
+func sessionPath() string {
+	if *savePath != "" {
+		return *savePath
+	}
+	if *loadPath != "" {
+		return *loadPath
+	}
+	return defaultSessionPath
+}
+
+// allowMethods returns whether r's method is one of methods. If it isn't, it
+// responds with 405 Method Not Allowed.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+// serve serves userGraph on :3000 until the process is killed.
+func serve() {
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// / matches everything, so we need to make sure we're at the root.
 		if r.URL.RequestURI() != "/" {
@@ -110,7 +443,12 @@ func main() {
 		mu.Lock()
 		defer mu.Unlock()
 
-		if err := json.NewEncoder(w).Encode(userGraph.connected(userGraph.root)); err != nil {
+		g, err := viewGraph(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(g.connected(g.root)); err != nil {
 			log.Println(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -118,6 +456,9 @@ func main() {
 	})
 
 	http.HandleFunc("/shoppingCart", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
 		if err := json.NewEncoder(w).Encode(shoppingCart); err != nil {
 			log.Println(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -129,8 +470,12 @@ func main() {
 		mu.Lock()
 		defer mu.Unlock()
 
-		userGraph = originalGraph.copy()
-		shoppingCart = make(map[string]map[string]struct{})
+		scenarios[currentScenario] = newScenario(originalGraph)
+		if err := useScenario(currentScenario); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
 		out := make(map[string]interface{})
 		out["graph"] = userGraph.edges
@@ -142,31 +487,150 @@ func main() {
 		}
 	})
 
+	// /hypotheticalCut accepts either a single {"from", "to"} edge, or a list of
+	// them to be evaluated together as one plan.
 	http.HandleFunc("/hypotheticalCut", func(w http.ResponseWriter, r *http.Request) {
-		var in map[string]string
-		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		var raw json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
 			log.Println(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		from := in["from"]
-		to := in["to"]
+		var in []map[string]string
+		if err := json.Unmarshal(raw, &in); err != nil {
+			var single map[string]string
+			if err := json.Unmarshal(raw, &single); err != nil {
+				log.Println(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			in = append(in, single)
+		}
 
 		mu.Lock()
 		defer mu.Unlock()
 
-		cutEdges, cutVertices, err := userGraph.hypotheticalCut(from, to)
+		out := make(map[string]interface{})
+		if len(in) == 1 {
+			// A single cut can be answered from the dominator tree.
+			cutEdges, cutVertices, err := userGraph.hypotheticalCut(in[0]["from"], in[0]["to"])
+			if err != nil {
+				log.Println(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			out["edges"] = cutEdges
+			out["vertices"] = cutVertices
+			out["impact"] = userGraph.impact(cutEdges, cutVertices)
+			out["modules"] = userGraph.prunedModules(cutVertices)
+			out["redundant"] = edgeMap{}
+		} else {
+			cuts := make(map[string]map[string]struct{})
+			for _, e := range in {
+				if _, ok := cuts[e["from"]]; !ok {
+					cuts[e["from"]] = make(map[string]struct{})
+				}
+				cuts[e["from"]][e["to"]] = struct{}{}
+			}
+			plan, err := userGraph.evaluatePlan(cuts)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			out["edges"] = plan.Edges
+			out["vertices"] = plan.Vertices
+			out["impact"] = plan.Impact
+			out["modules"] = plan.Modules
+			out["redundant"] = plan.Redundant
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+
+	http.HandleFunc("/recommendCut", func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "missing target", http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		rec, err := userGraph.recommendCut(target)
 		if err != nil {
 			log.Println(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if err := json.NewEncoder(w).Encode(rec); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
 
-		out := make(map[string]interface{})
-		out["edges"] = cutEdges
-		out["vertices"] = cutVertices
+	http.HandleFunc("/suggest", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		if s := r.URL.Query().Get("n"); s != "" {
+			var err error
+			if n, err = strconv.Atoi(s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
-		if err := json.NewEncoder(w).Encode(out); err != nil {
+		mu.Lock()
+		defer mu.Unlock()
+
+		g, err := viewGraph(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(g.suggestCuts(n)); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+
+	http.HandleFunc("/why", func(w http.ResponseWriter, r *http.Request) {
+		module := r.URL.Query().Get("module")
+		if module == "" {
+			http.Error(w, "missing module", http.StatusBadRequest)
+			return
+		}
+
+		maxPaths := defaultMaxPaths
+		if s := r.URL.Query().Get("max"); s != "" {
+			var err error
+			if maxPaths, err = strconv.Atoi(s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		g, err := viewGraph(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ex, err := g.why(module, maxPaths)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(ex); err != nil {
 			log.Println(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -174,6 +638,9 @@ func main() {
 	})
 
 	http.HandleFunc("/edge", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost, http.MethodDelete) {
+			return
+		}
 		var in map[string]string
 		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 			log.Println(err)
@@ -186,31 +653,381 @@ func main() {
 		mu.Lock()
 		defer mu.Unlock()
 
+		var op operation
 		if r.Method == http.MethodDelete { // Remove edge.
-			if _, ok := shoppingCart[from]; !ok {
-				shoppingCart[from] = make(map[string]struct{})
+			op = operation{Kind: cutEdge, From: from, To: to}
+		} else if r.Method == http.MethodPost { // Add an edge back.
+			op = operation{Kind: restoreEdge, From: from, To: to}
+		}
+		if err := opHistory.do(op, userGraph, shoppingCart); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		m := make(map[string]interface{})
+		m["graph"] = userGraph.connected(userGraph.root)
+		m["shoppingCart"] = shoppingCart
+		if err := json.NewEncoder(w).Encode(m); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+
+	http.HandleFunc("/vertex", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost, http.MethodDelete) {
+			return
+		}
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		vertex := in["vertex"]
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		var op operation
+		if r.Method == http.MethodDelete { // Remove every edge into the vertex.
+			op = operation{Kind: cutVertex, Vertex: vertex}
+		} else if r.Method == http.MethodPost { // Add the edges back.
+			op = operation{Kind: restoreVertex, Vertex: vertex}
+		}
+		if err := opHistory.do(op, userGraph, shoppingCart); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		m := make(map[string]interface{})
+		m["graph"] = userGraph.connected(userGraph.root)
+		m["shoppingCart"] = shoppingCart
+		if err := json.NewEncoder(w).Encode(m); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+
+	// /undo and /redo step backwards and forwards through the cuts and
+	// restores made with /edge and /vertex.
+	for path, step := range map[string]func(*history, *graph, *cart) (bool, error){
+		"/undo": (*history).undo,
+		"/redo": (*history).redo,
+	} {
+		step := step
+		http.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if _, err := step(opHistory, userGraph, shoppingCart); err != nil {
+				log.Println(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
 			}
-			shoppingCart[from][to] = struct{}{}
-			if err := userGraph.removeEdge(from, to); err != nil {
+
+			m := make(map[string]interface{})
+			m["graph"] = userGraph.connected(userGraph.root)
+			m["shoppingCart"] = shoppingCart
+			if err := json.NewEncoder(w).Encode(m); err != nil {
 				log.Println(err)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
 			}
-		} else if r.Method == http.MethodPost { // Add an edge back.
-			if _, ok := shoppingCart[from]; ok {
-				if _, ok := shoppingCart[from][to]; ok {
-					delete(shoppingCart[from], to)
+		})
+	}
+
+	// /diff responds with how the original graph differs from the graph it's
+	// being compared against, or 404 if it isn't being compared.
+	http.HandleFunc("/diff", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if diffBase == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(diffGraphs(diffBase, originalGraph)); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+
+	// /history responds with how the graph grew over the module's git
+	// history, or 404 if it isn't being served with its history.
+	http.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if growth == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(growth); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+
+	// /buildList responds with the build list that Minimal Version Selection
+	// picks for the current scenario, and how it differs from the original
+	// graph's.
+	http.HandleFunc("/buildList", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		after := userGraph.buildList()
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"buildList": buildListLabels(after),
+			"changes":   buildListChanges(originalGraph.buildList(), after),
+		}); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+
+	// /export responds with the current scenario's graph in the format given
+	// by ?format= (dot, graphml, mermaid or json). With ?cuts=true, the edges
+	// cut in the scenario are included and marked as cut.
+	http.HandleFunc("/export", func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = exportJSON
+		}
+		contentType, ok := exportContentTypes[format]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown export format %q", format), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		var cuts edgeMap
+		if r.URL.Query().Get("cuts") == "true" {
+			cuts = shoppingCart.cutEdges()
+		}
+		eg, err := userGraph.export(cuts)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", contentType)
+		if err := writeExport(w, eg, format); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+
+	// /events streams the progress of the background analysis as Server-Sent
+	// Events: a progress event, then a size or usages event for each vertex
+	// or edge as it's analyzed, then a done event. It's 404 if nothing is
+	// being analyzed in the background.
+	http.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		if analysis == nil {
+			http.NotFound(w, r)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		events, progress := analysis.subscribe()
+		defer analysis.unsubscribe(events)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		send := func(ev analysisEvent) bool {
+			b, err := json.Marshal(ev)
+			if err != nil {
+				log.Println(err)
+				return false
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind, b); err != nil {
+				return false
+			}
+			flusher.Flush()
+			return true
+		}
+
+		if !send(analysisEvent{Kind: analysisProgressKind, Progress: progress}) {
+			return
+		}
+		if events == nil {
+			send(analysis.doneEvent())
+			return
+		}
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					send(analysis.doneEvent())
+					return
 				}
+				if !send(ev) {
+					return
+				}
+			case <-r.Context().Done():
+				return
 			}
-			if err := userGraph.addEdge(from, to); err != nil {
+		}
+	})
+
+	http.HandleFunc("/scenarios", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := json.NewEncoder(w).Encode(summarizeScenarios()); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+
+	// /createScenario, /switchScenario and /deleteScenario manage the named
+	// scenarios. Each responds with the (possibly new) current scenario's
+	// graph and shopping cart, and a summary of every scenario.
+	http.HandleFunc("/createScenario", func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		name := in["name"]
+		clone := in["clone"] // Optional scenario to start from.
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if name == "" {
+			http.Error(w, "missing name", http.StatusBadRequest)
+			return
+		}
+		if _, ok := scenarios[name]; ok {
+			http.Error(w, fmt.Sprintf("scenario %s already exists", name), http.StatusBadRequest)
+			return
+		}
+		if clone == "" {
+			scenarios[name] = newScenario(originalGraph)
+		} else if s, ok := scenarios[clone]; ok {
+			scenarios[name] = s.clone()
+		} else {
+			http.Error(w, fmt.Sprintf("scenario %s does not exist", clone), http.StatusBadRequest)
+			return
+		}
+		if err := useScenario(name); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeScenario(w)
+	})
+
+	http.HandleFunc("/switchScenario", func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := useScenario(in["name"]); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeScenario(w)
+	})
+
+	http.HandleFunc("/deleteScenario", func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		name := in["name"]
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if _, ok := scenarios[name]; !ok {
+			http.Error(w, fmt.Sprintf("scenario %s does not exist", name), http.StatusBadRequest)
+			return
+		}
+		if len(scenarios) == 1 {
+			http.Error(w, "can't delete the only scenario", http.StatusBadRequest)
+			return
+		}
+		delete(scenarios, name)
+		if name == currentScenario {
+			// Fall back to whichever scenario sorts first.
+			if err := useScenario(summarizeScenarios()[0].Name); err != nil {
 				log.Println(err)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
 			}
 		}
+		writeScenario(w)
+	})
 
-		m := make(map[string]interface{})
-		m["graph"] = userGraph.connected(userGraph.root)
-		m["shoppingCart"] = shoppingCart
-		if err := json.NewEncoder(w).Encode(m); err != nil {
+	http.HandleFunc("/save", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := sessionPath()
+		if err := saveSession(path); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		out := make(map[string]interface{})
+		out["path"] = path
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+
+	http.HandleFunc("/hypotheticalVertexCut", func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		cutEdges, cutVertices, err := userGraph.hypotheticalVertexCut(in["vertex"])
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		out := make(map[string]interface{})
+		out["edges"] = cutEdges
+		out["vertices"] = cutVertices
+		out["impact"] = userGraph.impact(cutEdges, cutVertices)
+		out["modules"] = userGraph.prunedModules(cutVertices)
+		if err := json.NewEncoder(w).Encode(out); err != nil {
 			log.Println(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -220,3 +1037,35 @@ func main() {
 	log.Println("Listening on :3000")
 	log.Fatal(http.ListenAndServe(":3000", nil))
 }
+
+// viewGraph returns the graph that r's view query parameter asks for: the
+// current scenario's graph ("raw", the default), or the same graph with every
+// module collapsed to its selected version ("collapsed").
+//
+// mu must be held.
+func viewGraph(r *http.Request) (*graph, error) {
+	switch view := r.URL.Query().Get("view"); view {
+	case "", "raw":
+		return userGraph, nil
+	case "collapsed":
+		return userGraph.collapse(), nil
+	default:
+		return nil, fmt.Errorf("unknown view %q", view)
+	}
+}
+
+// writeScenario writes the current scenario's graph and shopping cart, and a
+// summary of every scenario.
+//
+// mu must be held.
+func writeScenario(w http.ResponseWriter) {
+	m := make(map[string]interface{})
+	m["graph"] = userGraph.connected(userGraph.root)
+	m["shoppingCart"] = shoppingCart
+	m["scenarios"] = summarizeScenarios()
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
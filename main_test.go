@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAllowMethods(t *testing.T) {
+	for _, tc := range []struct {
+		method     string
+		wantOK     bool
+		wantStatus int
+	}{
+		{method: http.MethodPost, wantOK: true, wantStatus: http.StatusOK},
+		{method: http.MethodDelete, wantOK: true, wantStatus: http.StatusOK},
+		{method: http.MethodGet, wantOK: false, wantStatus: http.StatusMethodNotAllowed},
+		{method: http.MethodPut, wantOK: false, wantStatus: http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tc.method, "/edge", nil)
+		if got := allowMethods(w, r, http.MethodPost, http.MethodDelete); got != tc.wantOK {
+			t.Errorf("%s: got %v, want %v", tc.method, got, tc.wantOK)
+		}
+		if w.Code != tc.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tc.method, w.Code, tc.wantStatus)
+		}
+		if !tc.wantOK {
+			if got, want := w.Header().Get("Allow"), "POST, DELETE"; got != want {
+				t.Errorf("%s: got Allow %q, want %q", tc.method, got, want)
+			}
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args        []string
+		wantCommand bool
+		wantArgs    []string
+	}{
+		{args: nil, wantCommand: false, wantArgs: nil},
+		{args: []string{"/path/to/module"}, wantCommand: false, wantArgs: []string{"/path/to/module"}},
+		{args: []string{"report", "-format", "csv"}, wantCommand: true, wantArgs: []string{"-format", "csv"}},
+		// A module directory named like a command is the command, unless it's
+		// given as a path.
+		{args: []string{"report"}, wantCommand: true, wantArgs: []string{}},
+		{args: []string{"./report"}, wantCommand: false, wantArgs: []string{"./report"}},
+		{args: []string{"a", "report"}, wantCommand: false, wantArgs: []string{"a", "report"}},
+	} {
+		run, args := parseArgs(tc.args)
+		if got := run != nil; got != tc.wantCommand {
+			t.Errorf("parseArgs(%q): got command %v, want %v", tc.args, got, tc.wantCommand)
+		}
+		if diff := cmp.Diff(tc.wantArgs, args); diff != "" {
+			t.Errorf("parseArgs(%q): got different args (-want +got):\n%s", tc.args, diff)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// usagesUnknown reports whether an edge's numUsages are unknown, because the
+// edge hasn't been or couldn't be analyzed. Suggestions and reports rank such
+// edges last, and recommendations only cut them when there's no way around
+// them: not knowing how much an edge is used doesn't make it free to cut.
+func usagesUnknown(numUsages int) bool {
+	return numUsages < 0
+}
+
+// formatUsages formats an edge's numUsages for printing.
+func formatUsages(numUsages int) string {
+	if usagesUnknown(numUsages) {
+		return "unknown"
+	}
+	return strconv.Itoa(numUsages)
+}
+
+// formatScore formats the score of cutting an edge with numUsages (see
+// suggestion.Score) for printing.
+func formatScore(numUsages int, score float64) string {
+	if usagesUnknown(numUsages) {
+		return "?"
+	}
+	return strconv.FormatFloat(score, 'f', 2, 64)
+}
+
+// cutCost is how expensive it is to cut e, whose usages must be known. Edges
+// with fewer usages are cheaper to cut. Every edge costs at least 1, so that
+// among edges with no usages, fewer cuts are preferred.
+func cutCost(e *edge) int {
+	return e.NumUsages + 1
+}
+
+// matchingVerticesLocked returns the labels of the vertices that module refers
+// to: either the vertex labelled module, or every version of the module path
+// module.
+//
+// g.mu must be held.
+func (g *graph) matchingVerticesLocked(module string) []string {
+	if _, ok := g.vertices[module]; ok {
+		return []string{module}
+	}
+	var out []string
+	for l := range g.vertices {
+		if path, _ := splitLabel(l); path == module {
+			out = append(out, l)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// cutRecommendation is the cheapest cut that removes a target module, and what
+// that cut would prune from the graph.
+type cutRecommendation struct {
+	Target string
+
+	// Cut is the set of edges to cut.
+	Cut edgeMap
+
+	// Edges and Vertices are everything pruned by the cut, including the cut
+	// edges themselves.
+	Edges    edgeMap
+	Vertices []string
+
+	Impact cutImpact
+}
+
+// recommendCut finds the cheapest cut that removes target (see minCut), and
+// evaluates it with hypotheticalCuts.
+func (g *graph) recommendCut(target string) (*cutRecommendation, error) {
+	cut, err := g.minCut(target)
+	if err != nil {
+		return nil, err
+	}
+	cuts := make(map[string]map[string]struct{})
+	for from, tos := range cut {
+		cuts[from] = make(map[string]struct{})
+		for to := range tos {
+			cuts[from][to] = struct{}{}
+		}
+	}
+	edges, vertices, err := g.hypotheticalCuts(cuts)
+	if err != nil {
+		return nil, err
+	}
+	return &cutRecommendation{
+		Target:   target,
+		Cut:      cut,
+		Edges:    edges,
+		Vertices: vertices,
+		Impact:   g.impact(edges, vertices),
+	}, nil
+}
+
+// minCut returns the cheapest set of edges (see cutCost) that, when cut,
+// disconnects every vertex matching target from the root. target may be a
+// vertex label, or a module path to match every version of the module. An edge
+// whose usages are unknown costs more than every other edge together, so it's
+// only cut when there's no way around it.
+//
+// It uses Edmonds-Karp to find a maximum flow from the root to the targets,
+// and returns the edges that cross from the root's side of the residual graph
+// to the targets' side.
+func (g *graph) minCut(target string) (edgeMap, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	targets := g.matchingVerticesLocked(target)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("vertex %s does not exist", target)
+	}
+	for _, t := range targets {
+		if t == g.root {
+			return nil, fmt.Errorf("can't cut the root %s from itself", g.root)
+		}
+	}
+
+	// Index the vertices, with one extra sink that every target flows into.
+	index := make(map[string]int)
+	var labels []string
+	for l := range g.vertices {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	for i, l := range labels {
+		index[l] = i
+	}
+	sink := len(labels)
+	n := sink + 1
+
+	type arc struct {
+		to, rev, capacity int
+		edge              *edge // Nil for reverse and sink arcs.
+	}
+	arcs := make([][]arc, n)
+	addArc := func(from, to, capacity int, e *edge) {
+		arcs[from] = append(arcs[from], arc{to: to, rev: len(arcs[to]), capacity: capacity, edge: e})
+		arcs[to] = append(arcs[to], arc{to: from, rev: len(arcs[from]) - 1})
+	}
+	unknownCost := 1
+	for _, from := range labels {
+		for _, e := range (*g.edges)[from] {
+			if !usagesUnknown(e.NumUsages) {
+				unknownCost += cutCost(e)
+			}
+		}
+	}
+	cost := func(e *edge) int {
+		if usagesUnknown(e.NumUsages) {
+			return unknownCost
+		}
+		return cutCost(e)
+	}
+	infinite := 1
+	for _, from := range labels {
+		for _, e := range (*g.edges)[from] {
+			infinite += cost(e)
+		}
+	}
+	for _, from := range labels {
+		tos := (*g.edges)[from]
+		var toLabels []string
+		for to := range tos {
+			toLabels = append(toLabels, to)
+		}
+		sort.Strings(toLabels)
+		for _, to := range toLabels {
+			addArc(index[from], index[to], cost(tos[to]), tos[to])
+		}
+	}
+	for _, t := range targets {
+		addArc(index[t], sink, infinite, nil)
+	}
+
+	root := index[g.root]
+	for {
+		// Find the shortest augmenting path.
+		prev := make([][2]int, n) // Vertex -> (previous vertex, arc index).
+		for i := range prev {
+			prev[i] = [2]int{-1, -1}
+		}
+		prev[root] = [2]int{root, -1}
+		queue := []int{root}
+		for len(queue) > 0 && prev[sink][0] == -1 {
+			v := queue[0]
+			queue = queue[1:]
+			for i, a := range arcs[v] {
+				if a.capacity > 0 && prev[a.to][0] == -1 {
+					prev[a.to] = [2]int{v, i}
+					queue = append(queue, a.to)
+				}
+			}
+		}
+		if prev[sink][0] == -1 {
+			break
+		}
+
+		// Push as much flow as the path allows.
+		flow := infinite
+		for v := sink; v != root; v = prev[v][0] {
+			if c := arcs[prev[v][0]][prev[v][1]].capacity; c < flow {
+				flow = c
+			}
+		}
+		for v := sink; v != root; v = prev[v][0] {
+			a := &arcs[prev[v][0]][prev[v][1]]
+			a.capacity -= flow
+			arcs[a.to][a.rev].capacity += flow
+		}
+	}
+
+	// Whatever is still reachable in the residual graph is on the root's side
+	// of the cut.
+	rootSide := make([]bool, n)
+	rootSide[root] = true
+	queue := []int{root}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, a := range arcs[v] {
+			if a.capacity > 0 && !rootSide[a.to] {
+				rootSide[a.to] = true
+				queue = append(queue, a.to)
+			}
+		}
+	}
+
+	cut := edgeMap{}
+	for v := range arcs {
+		if !rootSide[v] {
+			continue
+		}
+		for _, a := range arcs[v] {
+			if a.edge == nil || rootSide[a.to] {
+				continue
+			}
+			if _, ok := cut[a.edge.From.Label]; !ok {
+				cut[a.edge.From.Label] = make(map[string]*edge)
+			}
+			cut[a.edge.From.Label][a.edge.To.Label] = a.edge
+		}
+	}
+	return cut, nil
+}
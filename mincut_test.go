@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Implements ReplaceableASTParser with fixed usages, keyed by "from to".
+// Unknown edges have no usages.
+type mapASTParser map[string]int
+
+func (m mapASTParser) ModuleUsagesForModule(from, to string) (int, error) {
+	return m[from+" "+to], nil
+}
+
+func TestMinCut(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	defer func() { astParser = &testASTParser{} }()
+
+	for _, tc := range []struct {
+		desc   string
+		in     string
+		usages mapASTParser
+		// unknown maps from to the tos of edges whose usages are unknown.
+		unknown map[string][]string
+		target  string
+		want    [][2]string
+	}{
+		{
+			desc:   "single path",
+			in:     "a b\nb c",
+			usages: mapASTParser{"a b": 10, "b c": 1},
+			target: "c",
+			want:   [][2]string{{"b", "c"}},
+		},
+		{
+			desc:   "cheaper to cut above a fan-in",
+			in:     "a b\nb c\nb d\nc e\nd e",
+			usages: mapASTParser{"a b": 3, "b c": 5, "b d": 5, "c e": 5, "d e": 5},
+			target: "e",
+			want:   [][2]string{{"a", "b"}},
+		},
+		{
+			desc:   "both parents must be cut",
+			in:     "a b\na c\nb d\nc d",
+			usages: mapASTParser{"a b": 1, "a c": 2, "b d": 7, "c d": 0},
+			target: "d",
+			want:   [][2]string{{"a", "b"}, {"c", "d"}},
+		},
+		{
+			desc:   "module path matches every version",
+			in:     "a b@v1\na c\nc b@v2",
+			usages: mapASTParser{"a b@v1": 1, "a c": 9, "c b@v2": 1},
+			target: "b",
+			want:   [][2]string{{"a", "b@v1"}, {"c", "b@v2"}},
+		},
+		{
+			desc:    "unknown usages aren't a free cut",
+			in:      "a b\nb c",
+			usages:  mapASTParser{"b c": 0},
+			unknown: map[string][]string{"a": {"b"}},
+			target:  "c",
+			want:    [][2]string{{"b", "c"}},
+		},
+		{
+			desc:    "unknown usages cut when there's no way around them",
+			in:      "a b",
+			unknown: map[string][]string{"a": {"b"}},
+			target:  "b",
+			want:    [][2]string{{"a", "b"}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			astParser = tc.usages
+			g, err := newGraph(bytes.NewBufferString(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for from, tos := range tc.unknown {
+				for _, to := range tos {
+					(*g.edges)[from][to].NumUsages = -1
+				}
+			}
+			cut, err := g.minCut(tc.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][2]string
+			for _, e := range sortedEdges(cut) {
+				got = append(got, [2]string{e.From.Label, e.To.Label})
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got different cut (extraneous -, missing +):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRecommendCut(t *testing.T) {
+	moduleSizer = mapModuleSizer{"b": 10, "c": 100, "d": 1000}
+	astParser = mapASTParser{"a b": 1, "a c": 5, "b d": 1, "c d": 2}
+	defer func() {
+		moduleSizer = &testModuleSizer{}
+		astParser = &testASTParser{}
+	}()
+
+	g, err := newGraph(bytes.NewBufferString("a b\na c\nb d\nc d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := g.recommendCut("d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "d"}; !cmp.Equal(got.Vertices, want) {
+		t.Errorf("got vertices %v, want %v", got.Vertices, want)
+	}
+	if want := (cutImpact{SizeBytes: 1010, NumVertices: 2, NumEdges: 3}); got.Impact != want {
+		t.Errorf("got impact %+v, want %+v", got.Impact, want)
+	}
+}
+
+func TestMinCutErrors(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	g, err := newGraph(bytes.NewBufferString("a b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.minCut("z"); err == nil {
+		t.Error("expected error for unknown target, got nil")
+	}
+	if _, err := g.minCut("a"); err == nil {
+		t.Error("expected error for cutting the root, got nil")
+	}
+}
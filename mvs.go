@@ -0,0 +1,295 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// splitLabel splits a vertex label into its module path and version. Main
+// modules have no version.
+func splitLabel(label string) (path, version string) {
+	parts := strings.SplitN(label, "@", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// buildList returns the version of each module path that Minimal Version
+// Selection picks for g: the highest version of it that's reachable from the
+// root. Main modules, which have no version, are always selected.
+func (g *graph) buildList() map[string]string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.buildListLocked()
+}
+
+// buildListLocked is buildList.
+//
+// g.mu must be held.
+func (g *graph) buildListLocked() map[string]string {
+	out := make(map[string]string)
+	for label := range g.reachableLocked(nil) {
+		path, version := splitLabel(label)
+		selected, ok := out[path]
+		switch {
+		case !ok:
+			out[path] = version
+		case selected == "":
+			// Main modules win.
+		case version == "" || compareVersions(version, selected) > 0:
+			out[path] = version
+		}
+	}
+	return out
+}
+
+// versionChange is a module whose selected version differs between two build
+// lists.
+type versionChange struct {
+	Path string
+
+	// Before and After are the selected versions. Before is empty if the
+	// module is new to the build list, and After is empty if it's no longer in
+	// it at all.
+	Before, After string
+
+	// Downgrade is whether After is lower than Before.
+	Downgrade bool
+}
+
+// buildListChanges returns the modules whose selected version changed from
+// before to after, sorted by path. Main modules are left out, since they
+// aren't selected by version.
+func buildListChanges(before, after map[string]string) []versionChange {
+	out := []versionChange{}
+	for path, b := range before {
+		if b == "" {
+			continue
+		}
+		a, ok := after[path]
+		if ok && a == b {
+			continue
+		}
+		out = append(out, versionChange{
+			Path:      path,
+			Before:    b,
+			After:     a,
+			Downgrade: ok && compareVersions(a, b) < 0,
+		})
+	}
+	for path, a := range after {
+		if _, ok := before[path]; ok || a == "" {
+			continue
+		}
+		out = append(out, versionChange{Path: path, After: a})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// buildListLabels returns the labels of the modules in buildList, sorted.
+func buildListLabels(buildList map[string]string) []string {
+	out := []string{}
+	for path, version := range buildList {
+		if version == "" {
+			out = append(out, path)
+			continue
+		}
+		out = append(out, path+"@"+version)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// collapse returns a copy of g with one vertex per module path, at the version
+// that buildList selects. Every edge between two versions is moved onto the
+// selected versions, and edges that end up between the same two vertices are
+// merged by summing their usages. Vertices that aren't reachable from the root
+// are dropped.
+func (g *graph) collapse() *graph {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	reachable := g.reachableLocked(nil)
+	selected := g.buildListLocked()
+	collapsedLabel := func(label string) string {
+		path, _ := splitLabel(label)
+		if v := selected[path]; v != "" {
+			return path + "@" + v
+		}
+		return path
+	}
+
+	newg := &graph{
+		root:     g.root,
+		vertices: make(map[string]*Vertex),
+		edges:    &edgeMap{},
+	}
+	for path, version := range selected {
+		label := path
+		if version != "" {
+			label += "@" + version
+		}
+		v := *g.vertices[label]
+		newg.vertices[label] = &v
+	}
+
+	// Sum the usages of each collapsed edge. Unknown usages (-1) only count if
+	// nothing is known about any of the merged edges.
+	usages := make(map[string]map[string]int)
+	for from, tos := range *g.edges {
+		if _, ok := reachable[from]; !ok {
+			continue
+		}
+		cfrom := collapsedLabel(from)
+		for to, e := range tos {
+			cto := collapsedLabel(to)
+			if cfrom == cto {
+				continue
+			}
+			if _, ok := usages[cfrom]; !ok {
+				usages[cfrom] = make(map[string]int)
+			}
+			n, ok := usages[cfrom][cto]
+			switch {
+			case !ok || n < 0:
+				usages[cfrom][cto] = e.NumUsages
+			case e.NumUsages > 0:
+				usages[cfrom][cto] = n + e.NumUsages
+			}
+		}
+	}
+	for from, tos := range usages {
+		for to, n := range tos {
+			newg.edges.setUsages(newg.vertices[from], newg.vertices[to], n)
+		}
+	}
+	return newg
+}
+
+// compareVersions compares two semantic versions, returning -1, 0 or 1. Build
+// metadata, such as +incompatible, is ignored. Invalid versions sort before
+// valid ones.
+//
+// See https://semver.org/#spec-item-11.
+func compareVersions(v, w string) int {
+	pv, okv := parseVersion(v)
+	pw, okw := parseVersion(w)
+	switch {
+	case !okv && !okw:
+		return strings.Compare(v, w)
+	case !okv:
+		return -1
+	case !okw:
+		return 1
+	}
+	for i := 0; i < 3; i++ {
+		if c := compareNumbers(pv.numbers[i], pw.numbers[i]); c != 0 {
+			return c
+		}
+	}
+	return comparePrerelease(pv.prerelease, pw.prerelease)
+}
+
+// parsedVersion is a version's major, minor and patch numbers, and its
+// prerelease.
+type parsedVersion struct {
+	numbers    [3]string
+	prerelease string
+}
+
+// parseVersion parses vMAJOR[.MINOR[.PATCH]][-PRERELEASE][+BUILD]. Missing
+// minor and patch numbers are 0, as go does for shorthands like v2.
+func parseVersion(v string) (parsedVersion, bool) {
+	var p parsedVersion
+	if !strings.HasPrefix(v, "v") {
+		return p, false
+	}
+	v = v[1:]
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "-"); i >= 0 {
+		p.prerelease = v[i+1:]
+		if p.prerelease == "" {
+			return p, false
+		}
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) > 3 {
+		return p, false
+	}
+	p.numbers = [3]string{"0", "0", "0"}
+	for i, n := range parts {
+		if !isNumber(n) {
+			return p, false
+		}
+		p.numbers[i] = n
+	}
+	return p, true
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compareNumbers compares two decimal numbers of any length.
+func compareNumbers(x, y string) int {
+	x = strings.TrimLeft(x, "0")
+	y = strings.TrimLeft(y, "0")
+	if len(x) != len(y) {
+		if len(x) < len(y) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(x, y)
+}
+
+// comparePrerelease compares two prerelease strings. A version without a
+// prerelease sorts after every prerelease of it.
+func comparePrerelease(x, y string) int {
+	switch {
+	case x == y:
+		return 0
+	case x == "":
+		return 1
+	case y == "":
+		return -1
+	}
+	xs := strings.Split(x, ".")
+	ys := strings.Split(y, ".")
+	for i := 0; i < len(xs) && i < len(ys); i++ {
+		if xs[i] == ys[i] {
+			continue
+		}
+		xn, yn := isNumber(xs[i]), isNumber(ys[i])
+		switch {
+		case xn && yn:
+			return compareNumbers(xs[i], ys[i])
+		case xn:
+			return -1
+		case yn:
+			return 1
+		}
+		return strings.Compare(xs[i], ys[i])
+	}
+	switch {
+	case len(xs) < len(ys):
+		return -1
+	case len(xs) > len(ys):
+		return 1
+	}
+	return 0
+}
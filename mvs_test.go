@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		v, w string
+		want int
+	}{
+		{"v1.0.0", "v1.0.0", 0},
+		{"v1.0.0", "v1.0.1", -1},
+		{"v1.10.0", "v1.9.0", 1},
+		{"v2", "v2.0.0", 0},
+		{"v1.0.0-rc.1", "v1.0.0", -1},
+		{"v1.0.0-rc.2", "v1.0.0-rc.10", -1},
+		{"v1.0.0-alpha", "v1.0.0-1", 1},
+		{"v1.0.0-alpha", "v1.0.0-alpha.1", -1},
+		{"v0.0.0-20200101000000-abcdef123456", "v0.0.0-20191231000000-abcdef123456", 1},
+		{"v0.0.0-20200101000000-abcdef123456", "v0.1.0", -1},
+		{"v2.0.0+incompatible", "v2.0.0", 0},
+		{"bogus", "v0.0.1", -1},
+	} {
+		if got := compareVersions(tc.v, tc.w); got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.v, tc.w, got, tc.want)
+		}
+		if got := compareVersions(tc.w, tc.v); got != -tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.w, tc.v, got, -tc.want)
+		}
+	}
+}
+
+func TestBuildList(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	// d@v3 isn't reachable, so it isn't selected.
+	g, err := newGraph(bytes.NewBufferString("a b@v1.0.0\na c@v1.2.0\nb@v1.0.0 c@v1.10.0\nd@v3.0.0 c@v2.0.0\nc@v1.2.0 a@v0.1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "", "b": "v1.0.0", "c": "v1.10.0"}
+	if diff := cmp.Diff(g.buildList(), want); diff != "" {
+		t.Errorf("got different build list (extraneous -, missing +):\n%s", diff)
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = mapASTParser{
+		"a b@v1":    2,
+		"a c@v1":    1,
+		"b@v1 c@v2": 3,
+		"c@v1 d@v1": 4,
+		"c@v2 d@v2": -1,
+		"c@v2 b@v1": 5,
+	}
+	defer func() { astParser = &testASTParser{} }()
+
+	g, err := newGraph(bytes.NewBufferString("a b@v1\na c@v1\nb@v1 c@v2\nc@v1 d@v1\nc@v2 d@v2\nc@v2 b@v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := g.collapse()
+
+	want := edgeMap{}
+	for _, e := range []struct {
+		from, to  string
+		numUsages int
+	}{
+		{"a", "b@v1", 2},
+		{"a", "c@v2", 1},
+		{"b@v1", "c@v2", 3},
+		{"c@v2", "b@v1", 5},
+		// c@v1 -> d@v1 has usages, so they win over the unknown c@v2 -> d@v2.
+		{"c@v2", "d@v2", 4},
+	} {
+		want.setUsages(&Vertex{Label: e.from, SizeBytes: -1}, &Vertex{Label: e.to, SizeBytes: -1}, e.numUsages)
+	}
+	if diff := cmp.Diff(*got.edges, want, ignoreSizes); diff != "" {
+		t.Errorf("got different edges (extraneous -, missing +):\n%s", diff)
+	}
+	if got, want := len(got.vertices), 4; got != want {
+		t.Errorf("got %d vertices, want %d", got, want)
+	}
+	if got, want := len(g.vertices), 6; got != want {
+		t.Errorf("collapse changed the original graph: got %d vertices, want %d", got, want)
+	}
+}
+
+func TestBuildListChanges(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	// Cutting a -> b@v1 leaves only a's own requirement of c@v1.1 and d@v1,
+	// dropping b, downgrading c, and leaving d.
+	g, err := newGraph(bytes.NewBufferString("a b@v1.0.0\na c@v1.1.0\na d@v1.0.0\nb@v1.0.0 c@v1.3.0\nb@v1.0.0 d@v1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := g.buildList()
+	if err := g.removeEdge("a", "b@v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	after := g.buildList()
+
+	want := []versionChange{
+		{Path: "b", Before: "v1.0.0"},
+		{Path: "c", Before: "v1.3.0", After: "v1.1.0", Downgrade: true},
+	}
+	if diff := cmp.Diff(buildListChanges(before, after), want); diff != "" {
+		t.Errorf("got different changes (extraneous -, missing +):\n%s", diff)
+	}
+	if diff := cmp.Diff(buildListLabels(after), []string{"a", "c@v1.1.0", "d@v1.0.0"}); diff != "" {
+		t.Errorf("got different build list (extraneous -, missing +):\n%s", diff)
+	}
+}
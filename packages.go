@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jadekler/lean/internal"
+)
+
+// newPackageGraph creates a graph of packages from `go list -deps -json`
+// output. Its root is workspaceRoot, which has an edge to each package that
+// was listed by pattern rather than only as a dependency. Standard library
+// packages are left out, since no cut can remove them. Packages that can't be
+// sized or analyzed are marked as failed, rather than failing the graph.
+func newPackageGraph(packages []*internal.Package) (*graph, error) {
+	g := &graph{root: workspaceRoot, vertices: make(map[string]*Vertex), edges: &edgeMap{}}
+	root := &Vertex{Label: workspaceRoot}
+	g.vertices[workspaceRoot] = root
+
+	byPath := make(map[string]*internal.Package)
+	for _, p := range packages {
+		if p.Standard {
+			continue
+		}
+		v := &Vertex{Label: p.ImportPath}
+		sizeBytes, err := packageAnalyzer.PackageSize(p)
+		if err != nil {
+			sizeBytes = -1
+			v.AnalysisStatus = analysisFailed
+			v.AnalysisError = err.Error()
+		}
+		v.SizeBytes = sizeBytes
+		if p.Module != nil {
+			v.Module = p.Module.Label()
+		}
+		g.vertices[p.ImportPath] = v
+		byPath[p.ImportPath] = p
+	}
+	if len(byPath) == 0 {
+		return nil, fmt.Errorf("no non-standard packages to graph")
+	}
+
+	for path, p := range byPath {
+		if !p.DepOnly {
+			g.edges.setUsages(root, g.vertices[path], 0)
+		}
+		if len(p.Imports) == 0 {
+			continue
+		}
+		usages, err := packageAnalyzer.ImportUsages(p)
+		for _, imp := range p.Imports {
+			if _, ok := byPath[imp]; !ok {
+				continue
+			}
+			if err != nil {
+				g.edges.setCopy(g.vertices[path], g.vertices[imp], &edge{NumUsages: -1, AnalysisStatus: analysisFailed, AnalysisError: err.Error()})
+				continue
+			}
+			numUsages, ok := usages[imp]
+			if !ok {
+				numUsages = -1
+			}
+			g.edges.setUsages(g.vertices[path], g.vertices[imp], numUsages)
+		}
+	}
+	return g, nil
+}
+
+// prunedModules returns the modules that pruning the given vertices would
+// remove entirely from a package graph: those none of whose reachable packages
+// would be left. It's empty for module graphs.
+func (g *graph) prunedModules(pruned []string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	remaining := make(map[string]int)
+	for l := range g.reachableLocked(nil) {
+		if m := g.vertices[l].Module; m != "" {
+			remaining[m]++
+		}
+	}
+	for _, l := range pruned {
+		if m := g.vertices[l].Module; m != "" {
+			remaining[m]--
+		}
+	}
+	out := []string{}
+	for m, n := range remaining {
+		if n == 0 {
+			out = append(out, m)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
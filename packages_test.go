@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jadekler/lean/internal"
+)
+
+// Implements ReplaceablePackageAnalyzer with fixed usages, keyed by
+// "importer imported". Unknown usages are -1.
+type mapPackageAnalyzer map[string]int
+
+func (mapPackageAnalyzer) PackageSize(p *internal.Package) (int64, error) {
+	return int64(len(p.GoFiles)), nil
+}
+
+func (m mapPackageAnalyzer) ImportUsages(p *internal.Package) (map[string]int, error) {
+	out := make(map[string]int)
+	for _, imp := range p.Imports {
+		if n, ok := m[p.ImportPath+" "+imp]; ok {
+			out[imp] = n
+		}
+	}
+	return out, nil
+}
+
+// testPackages is a main module m with two packages, which import packages
+// from modules x and y.
+var testPackages = []*internal.Package{
+	{ImportPath: "fmt", Standard: true},
+	{ImportPath: "x/a", DepOnly: true, Module: &internal.Module{Path: "x", Version: "v1"}, GoFiles: []string{"a.go"}, Imports: []string{"fmt"}},
+	{ImportPath: "x/b", DepOnly: true, Module: &internal.Module{Path: "x", Version: "v1"}, GoFiles: []string{"b.go", "c.go"}},
+	{ImportPath: "y", DepOnly: true, Module: &internal.Module{Path: "y", Version: "v2"}, GoFiles: []string{"y.go"}, Imports: []string{"x/b"}},
+	{ImportPath: "m/cmd", Module: &internal.Module{Path: "m", Main: true}, Imports: []string{"fmt", "m/lib", "x/a"}},
+	{ImportPath: "m/lib", Module: &internal.Module{Path: "m", Main: true}, Imports: []string{"x/b", "y"}},
+}
+
+func TestNewPackageGraph(t *testing.T) {
+	packageAnalyzer = mapPackageAnalyzer{"m/cmd m/lib": 3, "m/cmd x/a": 1, "m/lib y": 2, "y x/b": 4}
+	defer func() { packageAnalyzer = &internal.PackageAnalyzer{} }()
+
+	g, err := newPackageGraph(testPackages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]int)
+	for from, tos := range *g.edges {
+		for to, e := range tos {
+			got[from+" "+to] = e.NumUsages
+		}
+	}
+	want := map[string]int{
+		workspaceRoot + " m/cmd": 0,
+		workspaceRoot + " m/lib": 0,
+		"m/cmd m/lib":            3,
+		"m/cmd x/a":              1,
+		"m/lib x/b":              -1,
+		"m/lib y":                2,
+		"y x/b":                  4,
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got different edges (extraneous -, missing +):\n%s", diff)
+	}
+
+	modules := make(map[string]string)
+	for l, v := range g.vertices {
+		modules[l] = v.Module
+	}
+	wantModules := map[string]string{workspaceRoot: "", "m/cmd": "m", "m/lib": "m", "x/a": "x@v1", "x/b": "x@v1", "y": "y@v2"}
+	if diff := cmp.Diff(modules, wantModules); diff != "" {
+		t.Errorf("got different modules (extraneous -, missing +):\n%s", diff)
+	}
+	if got, want := g.vertices["x/b"].SizeBytes, int64(2); got != want {
+		t.Errorf("got size %d for x/b, want %d", got, want)
+	}
+}
+
+func TestPrunedModules(t *testing.T) {
+	packageAnalyzer = mapPackageAnalyzer{}
+	defer func() { packageAnalyzer = &internal.PackageAnalyzer{} }()
+
+	g, err := newPackageGraph(testPackages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		cuts [][2]string
+		want []string
+	}{
+		{
+			desc: "x/b is still imported by y, so x keeps a package",
+			cuts: [][2]string{{"m/cmd", "x/a"}},
+			want: []string{},
+		},
+		{
+			desc: "y only has one package",
+			cuts: [][2]string{{"m/lib", "y"}},
+			want: []string{"y@v2"},
+		},
+		{
+			desc: "every import of x",
+			cuts: [][2]string{{"m/cmd", "x/a"}, {"m/lib", "x/b"}, {"y", "x/b"}},
+			want: []string{"x@v1"},
+		},
+	} {
+		cuts := make(map[string]map[string]struct{})
+		for _, c := range tc.cuts {
+			if _, ok := cuts[c[0]]; !ok {
+				cuts[c[0]] = make(map[string]struct{})
+			}
+			cuts[c[0]][c[1]] = struct{}{}
+		}
+		plan, err := g.evaluatePlan(cuts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(plan.Modules, tc.want); diff != "" {
+			t.Errorf("%s: got different modules (extraneous -, missing +):\n%s", tc.desc, diff)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// policy is the set of rules that `lean check` holds a graph to. Zero limits
+// aren't checked.
+type policy struct {
+	// MaxModules is the most modules that the graph may have, not counting
+	// workspaceRoot.
+	MaxModules int `json:"maxModules"`
+
+	// MaxBytes is the most bytes that the graph's modules may add up to.
+	MaxBytes int64 `json:"maxBytes"`
+
+	// Forbidden are modules that mustn't be in the graph. Each is a label, a
+	// module path to match any version of it, or a path ending in /... to
+	// match it and every module path under it.
+	Forbidden []string `json:"forbidden"`
+
+	// ForbiddenEdges are edges that mustn't be in the graph. From and To
+	// match vertices as Forbidden does.
+	ForbiddenEdges []policyEdge `json:"forbiddenEdges"`
+
+	// MaxDirectRetainedBytes is the most bytes that any direct dependency of
+	// the root, or of a main module in a workspace, may retain: that is, that
+	// cutting it would free.
+	MaxDirectRetainedBytes int64 `json:"maxDirectRetainedBytes"`
+}
+
+// policyEdge is an edge in a policy.
+type policyEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// readPolicy reads a policy from a JSON file.
+func readPolicy(path string) (*policy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &policy{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("error decoding policy %s: %v", path, err)
+	}
+	return p, nil
+}
+
+// checkResult is the outcome of checking one rule.
+type checkResult struct {
+	Rule   string
+	OK     bool
+	Detail string
+}
+
+// matchesPattern returns whether the vertex label matches a policy pattern.
+// See policy.Forbidden.
+func matchesPattern(label, pattern string) bool {
+	if label == pattern {
+		return true
+	}
+	path, _ := splitLabel(label)
+	if prefix := strings.TrimSuffix(pattern, "/..."); prefix != pattern {
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+	return path == pattern
+}
+
+// checkPolicy checks what's reachable from g's root against p, returning the
+// outcome of each rule in p.
+func (g *graph) checkPolicy(p *policy) []checkResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	reachable := g.reachableLocked(nil)
+	var labels []string
+	for l := range reachable {
+		if l != workspaceRoot {
+			labels = append(labels, l)
+		}
+	}
+	sort.Strings(labels)
+
+	var out []checkResult
+	if p.MaxModules > 0 {
+		out = append(out, checkResult{
+			Rule:   fmt.Sprintf("at most %d modules", p.MaxModules),
+			OK:     len(labels) <= p.MaxModules,
+			Detail: fmt.Sprintf("%d modules", len(labels)),
+		})
+	}
+	if p.MaxBytes > 0 {
+		total := g.totalLocked(reachable)
+		out = append(out, checkResult{
+			Rule:   fmt.Sprintf("at most %d bytes", p.MaxBytes),
+			OK:     total.SizeBytes <= p.MaxBytes,
+			Detail: fmt.Sprintf("%d bytes", total.SizeBytes),
+		})
+	}
+
+	for _, pattern := range p.Forbidden {
+		var matches []string
+		for _, l := range labels {
+			if matchesPattern(l, pattern) {
+				matches = append(matches, l)
+			}
+		}
+		r := checkResult{Rule: fmt.Sprintf("no module matching %s", pattern), OK: len(matches) == 0}
+		if !r.OK {
+			r.Detail = "found " + strings.Join(matches, ", ")
+		}
+		out = append(out, r)
+	}
+
+	for _, pe := range p.ForbiddenEdges {
+		var matches []string
+		for _, from := range labels {
+			if !matchesPattern(from, pe.From) {
+				continue
+			}
+			for to := range (*g.edges)[from] {
+				if matchesPattern(to, pe.To) {
+					matches = append(matches, from+" -> "+to)
+				}
+			}
+		}
+		sort.Strings(matches)
+		r := checkResult{Rule: fmt.Sprintf("no edge from %s to %s", pe.From, pe.To), OK: len(matches) == 0}
+		if !r.OK {
+			r.Detail = "found " + strings.Join(matches, ", ")
+		}
+		out = append(out, r)
+	}
+
+	if p.MaxDirectRetainedBytes > 0 {
+		g.dominatorsLocked()
+		mains := map[string]struct{}{g.root: {}}
+		if g.root == workspaceRoot {
+			mains = map[string]struct{}{}
+			for to := range (*g.edges)[workspaceRoot] {
+				mains[to] = struct{}{}
+			}
+		}
+		seen := map[string]struct{}{}
+		var over []string
+		for from := range mains {
+			for to := range (*g.edges)[from] {
+				if _, ok := mains[to]; ok {
+					continue
+				}
+				if _, ok := seen[to]; ok {
+					continue
+				}
+				seen[to] = struct{}{}
+				if b := g.vertices[to].RetainedBytes; b > p.MaxDirectRetainedBytes {
+					over = append(over, fmt.Sprintf("%s (%d bytes)", to, b))
+				}
+			}
+		}
+		sort.Strings(over)
+		r := checkResult{
+			Rule: fmt.Sprintf("no direct dependency retaining more than %d bytes", p.MaxDirectRetainedBytes),
+			OK:   len(over) == 0,
+		}
+		if !r.OK {
+			r.Detail = "found " + strings.Join(over, ", ")
+		}
+		out = append(out, r)
+	}
+	return out
+}
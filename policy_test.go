@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	moduleSizer = mapModuleSizer{"a": 1, "b@v1": 10, "c@v1": 100, "x.org/d@v1": 1000, "x.org/d/e@v2": 10000}
+	astParser = &testASTParser{}
+	defer func() { moduleSizer = &testModuleSizer{} }()
+
+	g, err := newGraph(bytes.NewBufferString("a b@v1\na c@v1\nb@v1 x.org/d@v1\nc@v1 x.org/d/e@v2\nx.org/d/e@v2 x.org/d@v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		policy policy
+		want   []checkResult
+	}{
+		{
+			name:   "within limits",
+			policy: policy{MaxModules: 5, MaxBytes: 11111, MaxDirectRetainedBytes: 10100},
+			want: []checkResult{
+				{Rule: "at most 5 modules", OK: true, Detail: "5 modules"},
+				{Rule: "at most 11111 bytes", OK: true, Detail: "11111 bytes"},
+				{Rule: "no direct dependency retaining more than 10100 bytes", OK: true},
+			},
+		},
+		{
+			name:   "over limits",
+			policy: policy{MaxModules: 4, MaxBytes: 11110, MaxDirectRetainedBytes: 10},
+			want: []checkResult{
+				{Rule: "at most 4 modules", OK: false, Detail: "5 modules"},
+				{Rule: "at most 11110 bytes", OK: false, Detail: "11111 bytes"},
+				{Rule: "no direct dependency retaining more than 10 bytes", OK: false, Detail: "found c@v1 (10100 bytes)"},
+			},
+		},
+		{
+			name:   "forbidden modules",
+			policy: policy{Forbidden: []string{"b@v1", "c", "x.org/d/...", "x.org/d/e@v1", "y.org"}},
+			want: []checkResult{
+				{Rule: "no module matching b@v1", OK: false, Detail: "found b@v1"},
+				{Rule: "no module matching c", OK: false, Detail: "found c@v1"},
+				{Rule: "no module matching x.org/d/...", OK: false, Detail: "found x.org/d/e@v2, x.org/d@v1"},
+				{Rule: "no module matching x.org/d/e@v1", OK: true},
+				{Rule: "no module matching y.org", OK: true},
+			},
+		},
+		{
+			name: "forbidden edges",
+			policy: policy{ForbiddenEdges: []policyEdge{
+				{From: "x.org/...", To: "x.org/d"},
+				{From: "a", To: "x.org/d"},
+			}},
+			want: []checkResult{
+				{Rule: "no edge from x.org/... to x.org/d", OK: false, Detail: "found x.org/d/e@v2 -> x.org/d@v1"},
+				{Rule: "no edge from a to x.org/d", OK: true},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := g.checkPolicy(&test.policy)
+			if diff := cmp.Diff(got, test.want); diff != "" {
+				t.Errorf("got different results (extraneous -, missing +):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// reportRow is one edge in a report, and what cutting it alone would prune.
+type reportRow struct {
+	From string
+	To   string
+
+	// SizeBytes is the size of To.
+	SizeBytes int64
+	NumUsages int
+
+	// Ratio is the bytes freed per unit of cutCost, or 0 if NumUsages is
+	// unknown (-1). See suggestion.Score.
+	Ratio float64
+
+	VerticesFreed int
+	BytesFreed    int64
+}
+
+// report returns a row for every edge reachable from g's root, best cut first,
+// and edges whose usages are unknown last (see suggestCuts). Ties are broken by
+// from and then to, so the order is deterministic.
+func (g *graph) report() []reportRow {
+	out := []reportRow{}
+	for _, s := range g.suggestCuts(0) {
+		out = append(out, reportRow{
+			From:          s.Edge.From.Label,
+			To:            s.Edge.To.Label,
+			SizeBytes:     s.Edge.To.SizeBytes,
+			NumUsages:     s.Edge.NumUsages,
+			Ratio:         s.Score,
+			VerticesFreed: s.Impact.NumVertices,
+			BytesFreed:    s.Impact.SizeBytes,
+		})
+	}
+	return out
+}
+
+// Report formats.
+const (
+	reportText = "text"
+	reportJSON = "json"
+	reportCSV  = "csv"
+)
+
+// writeReport writes rows to w in the given format.
+func writeReport(w io.Writer, rows []reportRow, format string) error {
+	header := []string{"FROM", "TO", "SIZE BYTES", "USAGES", "RATIO", "VERTICES FREED", "BYTES FREED"}
+	fields := func(r reportRow) []string {
+		return []string{
+			r.From,
+			r.To,
+			strconv.FormatInt(r.SizeBytes, 10),
+			formatUsages(r.NumUsages),
+			formatScore(r.NumUsages, r.Ratio),
+			strconv.Itoa(r.VerticesFreed),
+			strconv.FormatInt(r.BytesFreed, 10),
+		}
+	}
+
+	switch format {
+	case reportText:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		writeRow := func(fs []string) {
+			for i, f := range fs {
+				if i > 0 {
+					fmt.Fprint(tw, "\t")
+				}
+				fmt.Fprint(tw, f)
+			}
+			fmt.Fprintln(tw)
+		}
+		writeRow(header)
+		for _, r := range rows {
+			writeRow(fields(r))
+		}
+		return tw.Flush()
+	case reportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case reportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		for _, r := range rows {
+			cw.Write(fields(r))
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown report format %q: want %s, %s or %s", format, reportText, reportJSON, reportCSV)
+	}
+}
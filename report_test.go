@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReport(t *testing.T) {
+	moduleSizer = mapModuleSizer{"a": 1, "b": 10, "c": 100, "d": 1000}
+	astParser = mapASTParser{"a b": 1, "a c": 3, "b d": 0, "c d": 0}
+	defer func() {
+		moduleSizer = &testModuleSizer{}
+		astParser = &testASTParser{}
+	}()
+
+	g, err := newGraph(bytes.NewBufferString("a b\na c\nb d\nc d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := g.report()
+	want := []reportRow{
+		{From: "a", To: "c", SizeBytes: 100, NumUsages: 3, Ratio: 25, VerticesFreed: 1, BytesFreed: 100},
+		{From: "a", To: "b", SizeBytes: 10, NumUsages: 1, Ratio: 5, VerticesFreed: 1, BytesFreed: 10},
+		{From: "b", To: "d", SizeBytes: 1000, NumUsages: 0},
+		{From: "c", To: "d", SizeBytes: 1000, NumUsages: 0},
+	}
+	if diff := cmp.Diff(rows, want); diff != "" {
+		t.Fatalf("got different rows (extraneous -, missing +):\n%s", diff)
+	}
+
+	var text bytes.Buffer
+	if err := writeReport(&text, rows[:2], reportText); err != nil {
+		t.Fatal(err)
+	}
+	wantText := `FROM  TO  SIZE BYTES  USAGES  RATIO  VERTICES FREED  BYTES FREED
+a     c   100         3       25.00  1               100
+a     b   10          1       5.00   1               10
+`
+	if diff := cmp.Diff(text.String(), wantText); diff != "" {
+		t.Errorf("got different text (extraneous -, missing +):\n%s", diff)
+	}
+
+	var csv bytes.Buffer
+	if err := writeReport(&csv, rows[:1], reportCSV); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "FROM,TO,SIZE BYTES,USAGES,RATIO,VERTICES FREED,BYTES FREED\na,c,100,3,25.00,1,100\n"
+	if diff := cmp.Diff(csv.String(), wantCSV); diff != "" {
+		t.Errorf("got different csv (extraneous -, missing +):\n%s", diff)
+	}
+
+	var js bytes.Buffer
+	if err := writeReport(&js, rows, reportJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []reportRow
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(decoded, rows); diff != "" {
+		t.Errorf("got different json (extraneous -, missing +):\n%s", diff)
+	}
+
+	if err := writeReport(&js, rows, "xml"); err == nil {
+		t.Error("got no error for an unknown format")
+	}
+
+	// An edge whose usages are unknown isn't a free cut: it's ranked last, and
+	// printed as unknown rather than as a ratio of 0.
+	(*g.edges)["a"]["c"].NumUsages = -1
+	rows = g.report()
+	if last := rows[len(rows)-1]; last.From != "a" || last.To != "c" {
+		t.Errorf("got last row %s -> %s, want a -> c", last.From, last.To)
+	}
+	csv.Reset()
+	if err := writeReport(&csv, rows[len(rows)-1:], reportCSV); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV = "FROM,TO,SIZE BYTES,USAGES,RATIO,VERTICES FREED,BYTES FREED\na,c,100,unknown,?,1,100\n"
+	if diff := cmp.Diff(csv.String(), wantCSV); diff != "" {
+		t.Errorf("got different csv for unknown usages (extraneous -, missing +):\n%s", diff)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// defaultScenario is the name of the scenario lean starts with.
+const defaultScenario = "default"
+
+// scenario is a named set of cuts, and the graph that results from them.
+type scenario struct {
+	graph   *graph
+	cart    *cart
+	history *history
+}
+
+// newScenario creates a scenario with no cuts made to original.
+func newScenario(original *graph) *scenario {
+	return &scenario{graph: original.copy(), cart: newCart(), history: &history{}}
+}
+
+// clone creates a copy of s that can be changed independently of it.
+func (s *scenario) clone() *scenario {
+	g := s.graph.copy()
+
+	// The cut edges are copied too, and point at the copy's vertices, so that
+	// analyzing or restoring them in one scenario doesn't change the other.
+	copyEdges := func(em edgeMap) edgeMap {
+		out := edgeMap{}
+		for _, tos := range em {
+			for _, e := range tos {
+				out.setCopy(g.vertices[e.From.Label], g.vertices[e.To.Label], e)
+			}
+		}
+		return out
+	}
+	c := newCart()
+	c.Edges = copyEdges(s.cart.Edges)
+	for v, inEdges := range s.cart.Vertices {
+		c.addVertex(v, copyEdges(inEdges))
+	}
+	return &scenario{
+		graph:   g,
+		cart:    c,
+		history: &history{ops: append([]operation(nil), s.history.ops...), next: s.history.next},
+	}
+}
+
+// scenarioSummary is how much a scenario has removed from the original graph.
+type scenarioSummary struct {
+	Name    string
+	Current bool
+
+	// Removed is what is reachable from the root in the original graph, but
+	// not in the scenario's graph.
+	Removed cutImpact
+}
+
+// summarize compares g against original.
+func summarize(original, g *graph) cutImpact {
+	original.mu.Lock()
+	before := original.reachableLocked(nil)
+	beforeEdges := make(map[[2]string]struct{})
+	for from := range before {
+		for to := range (*original.edges)[from] {
+			beforeEdges[[2]string{from, to}] = struct{}{}
+		}
+	}
+	original.mu.Unlock()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	after := g.reachableLocked(nil)
+
+	var out cutImpact
+	for v := range before {
+		if _, ok := after[v]; ok {
+			continue
+		}
+		out.NumVertices++
+		if s := g.vertices[v].SizeBytes; s > 0 {
+			out.SizeBytes += s
+		}
+	}
+	for e := range beforeEdges {
+		if _, ok := after[e[0]]; ok {
+			if _, ok := (*g.edges)[e[0]][e[1]]; ok {
+				continue
+			}
+		}
+		out.NumEdges++
+	}
+	return out
+}
+
+// useScenario makes the named scenario the one that userGraph, shoppingCart
+// and opHistory refer to.
+//
+// mu must be held.
+func useScenario(name string) error {
+	s, ok := scenarios[name]
+	if !ok {
+		return fmt.Errorf("scenario %s does not exist", name)
+	}
+	currentScenario = name
+	userGraph = s.graph
+	shoppingCart = s.cart
+	opHistory = s.history
+	return nil
+}
+
+// summarizeScenarios summarizes every scenario, sorted by name.
+//
+// mu must be held.
+func summarizeScenarios() []scenarioSummary {
+	var out []scenarioSummary
+	for name, s := range scenarios {
+		out = append(out, scenarioSummary{
+			Name:    name,
+			Current: name == currentScenario,
+			Removed: summarize(originalGraph, s.graph),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestScenarioClone(t *testing.T) {
+	moduleSizer = mapModuleSizer{"b": 10, "c": 100}
+	astParser = &testASTParser{}
+	defer func() { moduleSizer = &testModuleSizer{} }()
+
+	original, err := newGraph(bytes.NewBufferString("a b\nb c\na d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newScenario(original)
+	if err := s.history.do(operation{Kind: cutEdge, From: "b", To: "c"}, s.graph, s.cart); err != nil {
+		t.Fatal(err)
+	}
+
+	clone := s.clone()
+	if err := clone.history.do(operation{Kind: cutEdge, From: "a", To: "b"}, clone.graph, clone.cart); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := summarize(original, s.graph), (cutImpact{SizeBytes: 100, NumVertices: 1, NumEdges: 1}); got != want {
+		t.Errorf("got original scenario summary %+v, want %+v", got, want)
+	}
+	if got, want := summarize(original, clone.graph), (cutImpact{SizeBytes: 110, NumVertices: 2, NumEdges: 2}); got != want {
+		t.Errorf("got cloned scenario summary %+v, want %+v", got, want)
+	}
+	if got := len(s.cart.Edges["a"]); got != 0 {
+		t.Errorf("cutting in the clone changed the original's cart")
+	}
+
+	// Undoing in the clone undoes the clone's own cut first, then the one it
+	// inherited.
+	for i := 0; i < 2; i++ {
+		if ok, err := clone.history.undo(clone.graph, clone.cart); err != nil || !ok {
+			t.Fatalf("undo %d: got %v, %v", i, ok, err)
+		}
+	}
+	if got, want := summarize(original, clone.graph), (cutImpact{}); got != want {
+		t.Errorf("got summary %+v after undoing everything, want %+v", got, want)
+	}
+	if got, want := summarize(original, s.graph).NumVertices, 1; got != want {
+		t.Errorf("undoing in the clone changed the original: got %d vertices removed, want %d", got, want)
+	}
+}
+
+func TestScenarioCloneSharesNothing(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	original, err := newGraph(bytes.NewBufferString("a b\nb c\nb d\nc d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newScenario(original)
+	if err := s.history.do(operation{Kind: cutEdge, From: "a", To: "b"}, s.graph, s.cart); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.history.do(operation{Kind: cutVertex, Vertex: "d"}, s.graph, s.cart); err != nil {
+		t.Fatal(err)
+	}
+	clone := s.clone()
+
+	// Cut edges point at the clone's own vertices.
+	for _, e := range sortedEdges(clone.cart.cutEdges()) {
+		if e.From != clone.graph.vertices[e.From.Label] || e.To != clone.graph.vertices[e.To.Label] {
+			t.Errorf("cut edge %s points at vertices outside the clone's graph", e)
+		}
+	}
+
+	// Analyzing the clone's cut edges, and restoring one of a cut vertex,
+	// leaves the original's cart as it was.
+	clone.cart.applyAnalysis(analysisEvent{Kind: analysisUsagesKind, From: "a", To: "b", NumUsages: 42})
+	clone.cart.applyAnalysis(analysisEvent{Kind: analysisUsagesKind, From: "c", To: "d", NumUsages: 42})
+	if err := clone.history.do(operation{Kind: restoreEdge, From: "b", To: "d"}, clone.graph, clone.cart); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.cart.Edges["a"]["b"].NumUsages; got == 42 {
+		t.Error("analyzing the clone's cut edge a-b changed the original's")
+	}
+	if got := s.cart.Vertices["d"]["c"]["d"].NumUsages; got == 42 {
+		t.Error("analyzing the clone's cut edge c-d changed the original's")
+	}
+	if _, ok := s.cart.Vertices["d"]["b"]["d"]; !ok {
+		t.Error("restoring b-d in the clone restored it in the original's cart")
+	}
+	if _, err := s.graph.edge("b", "d"); err == nil {
+		t.Error("restoring b-d in the clone restored it in the original's graph")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// sessionVersion is the version of the session file format. It must be bumped
+// whenever the format changes incompatibly.
+const sessionVersion = 1
+
+// session is everything needed to resume work without re-running the module
+// size and AST analysis: the input graph with its sizes and usages, and the
+// cuts made in every scenario.
+type session struct {
+	Version  int
+	Root     string
+	Vertices []savedVertex
+	Edges    []savedEdge
+
+	CurrentScenario string
+	Scenarios       []savedScenario
+}
+
+type savedVertex struct {
+	Label     string
+	SizeBytes int64
+
+	// Module metadata from `go list -m -json all`. See Vertex.
+	Main       bool   `json:",omitempty"`
+	Indirect   bool   `json:",omitempty"`
+	Replace    string `json:",omitempty"`
+	GoVersion  string `json:",omitempty"`
+	Deprecated string `json:",omitempty"`
+	GoMod      string `json:",omitempty"`
+
+	// Module is the owning module of a package in a package graph.
+	Module string `json:",omitempty"`
+
+	// Whether the vertex has been sized. See Vertex.
+	AnalysisStatus string `json:",omitempty"`
+	AnalysisError  string `json:",omitempty"`
+}
+
+type savedEdge struct {
+	From, To  string
+	NumUsages int
+
+	// Whether the edge has been analyzed. See Vertex.
+	AnalysisStatus string `json:",omitempty"`
+	AnalysisError  string `json:",omitempty"`
+}
+
+// savedScenario is a scenario's history. Replaying the first Next operations
+// recreates its graph and shopping cart; the rest can be redone.
+type savedScenario struct {
+	Name       string
+	Operations []operation
+	Next       int
+}
+
+// savedASTParser answers with the usages saved in a session, so that restoring
+// a cut edge while replaying the session doesn't analyze it again. It falls
+// back to another parser for edges it doesn't know.
+type savedASTParser struct {
+	usages   map[string]map[string]int
+	fallback ReplaceableASTParser
+}
+
+func (p *savedASTParser) ModuleUsagesForModule(from, to string) (int, error) {
+	if n, ok := p.usages[from][to]; ok {
+		return n, nil
+	}
+	return p.fallback.ModuleUsagesForModule(from, to)
+}
+
+// saveSession writes originalGraph and every scenario to path.
+//
+// mu must be held.
+func saveSession(path string) error {
+	s := session{
+		Version:         sessionVersion,
+		Root:            originalGraph.root,
+		CurrentScenario: currentScenario,
+	}
+
+	originalGraph.mu.Lock()
+	for _, v := range originalGraph.vertices {
+		s.Vertices = append(s.Vertices, savedVertex{
+			Label:      v.Label,
+			SizeBytes:  v.SizeBytes,
+			Main:       v.Main,
+			Indirect:   v.Indirect,
+			Replace:    v.Replace,
+			GoVersion:  v.GoVersion,
+			Deprecated: v.Deprecated,
+			GoMod:      v.GoMod,
+			Module:     v.Module,
+
+			AnalysisStatus: v.AnalysisStatus,
+			AnalysisError:  v.AnalysisError,
+		})
+	}
+	for _, e := range sortedEdges(*originalGraph.edges) {
+		s.Edges = append(s.Edges, savedEdge{
+			From:           e.From.Label,
+			To:             e.To.Label,
+			NumUsages:      e.NumUsages,
+			AnalysisStatus: e.AnalysisStatus,
+			AnalysisError:  e.AnalysisError,
+		})
+	}
+	originalGraph.mu.Unlock()
+	sort.Slice(s.Vertices, func(i, j int) bool { return s.Vertices[i].Label < s.Vertices[j].Label })
+
+	for name, sc := range scenarios {
+		s.Scenarios = append(s.Scenarios, savedScenario{Name: name, Operations: sc.history.ops, Next: sc.history.next})
+	}
+	sort.Slice(s.Scenarios, func(i, j int) bool { return s.Scenarios[i].Name < s.Scenarios[j].Name })
+
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// loadSession reads originalGraph and every scenario from path, replacing
+// whatever was there before.
+//
+// mu must be held.
+func loadSession(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var s session
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("error decoding session %s: %v", path, err)
+	}
+	if s.Version != sessionVersion {
+		return fmt.Errorf("session %s has version %d, but this lean reads version %d", path, s.Version, sessionVersion)
+	}
+
+	g := &graph{root: s.Root, vertices: make(map[string]*Vertex), edges: &edgeMap{}}
+	for _, v := range s.Vertices {
+		g.vertices[v.Label] = &Vertex{
+			Label:      v.Label,
+			SizeBytes:  v.SizeBytes,
+			Main:       v.Main,
+			Indirect:   v.Indirect,
+			Replace:    v.Replace,
+			GoVersion:  v.GoVersion,
+			Deprecated: v.Deprecated,
+			GoMod:      v.GoMod,
+			Module:     v.Module,
+
+			AnalysisStatus: v.AnalysisStatus,
+			AnalysisError:  v.AnalysisError,
+		}
+	}
+	for _, e := range s.Edges {
+		from, ok := g.vertices[e.From]
+		if !ok {
+			return fmt.Errorf("session %s has an edge from unknown vertex %s", path, e.From)
+		}
+		to, ok := g.vertices[e.To]
+		if !ok {
+			return fmt.Errorf("session %s has an edge to unknown vertex %s", path, e.To)
+		}
+		g.edges.setCopy(from, to, &edge{NumUsages: e.NumUsages, AnalysisStatus: e.AnalysisStatus, AnalysisError: e.AnalysisError})
+	}
+
+	// A session saved while the analysis was still running has vertices and
+	// edges whose analysis is pending. They're analyzed now, before the
+	// scenarios copy them.
+	analyzeGraph(g, moduleSizer, astParser, g.applyAnalysis)
+
+	// Replaying the scenarios can restore edges, which shouldn't be analyzed
+	// again.
+	usages := make(map[string]map[string]int)
+	for from, tos := range *g.edges {
+		for to, e := range tos {
+			if e.AnalysisStatus != "" {
+				continue
+			}
+			if _, ok := usages[from]; !ok {
+				usages[from] = make(map[string]int)
+			}
+			usages[from][to] = e.NumUsages
+		}
+	}
+	defer func(p ReplaceableASTParser) { astParser = p }(astParser)
+	astParser = &savedASTParser{usages: usages, fallback: astParser}
+
+	loaded := make(map[string]*scenario)
+	for _, saved := range s.Scenarios {
+		sc := newScenario(g)
+		if saved.Next < 0 || saved.Next > len(saved.Operations) {
+			return fmt.Errorf("session %s: scenario %s has %d operations but %d applied", path, saved.Name, len(saved.Operations), saved.Next)
+		}
+		for _, op := range saved.Operations[:saved.Next] {
+			if err := sc.history.do(op, sc.graph, sc.cart); err != nil {
+				return fmt.Errorf("session %s: scenario %s: %v", path, saved.Name, err)
+			}
+		}
+		sc.history.ops = append(sc.history.ops, saved.Operations[saved.Next:]...)
+		loaded[saved.Name] = sc
+	}
+	if len(loaded) == 0 {
+		loaded[defaultScenario] = newScenario(g)
+		s.CurrentScenario = defaultScenario
+	}
+
+	originalGraph = g
+	scenarios = loaded
+	return useScenario(s.CurrentScenario)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Implements ReplaceableASTParser, failing the test if it is ever used.
+type failingASTParser struct{ t *testing.T }
+
+func (p *failingASTParser) ModuleUsagesForModule(from, to string) (int, error) {
+	p.t.Errorf("unexpected analysis of edge (%s, %s)", from, to)
+	return 0, nil
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	moduleSizer = mapModuleSizer{"b": 10, "c": 100}
+	astParser = mapASTParser{"a b": 3, "b c": 7}
+	defer func() {
+		moduleSizer = &testModuleSizer{}
+		astParser = &testASTParser{}
+	}()
+
+	dir, err := ioutil.TempDir("", "lean-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	originalGraph, err = newGraph(bytes.NewBufferString("a b\nb c\na d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenarios = map[string]*scenario{defaultScenario: newScenario(originalGraph)}
+	if err := useScenario(defaultScenario); err != nil {
+		t.Fatal(err)
+	}
+	if err := opHistory.do(operation{Kind: cutEdge, From: "b", To: "c"}, userGraph, shoppingCart); err != nil {
+		t.Fatal(err)
+	}
+	if err := opHistory.do(operation{Kind: cutVertex, Vertex: "d"}, userGraph, shoppingCart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := opHistory.undo(userGraph, shoppingCart); err != nil {
+		t.Fatal(err)
+	}
+	scenarios["other"] = newScenario(originalGraph)
+
+	if err := saveSession(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Loading must not analyze anything again.
+	failing := &failingASTParser{t: t}
+	astParser = failing
+	originalGraph, scenarios, userGraph, shoppingCart, opHistory = nil, nil, nil, nil, nil
+	if err := loadSession(path); err != nil {
+		t.Fatal(err)
+	}
+	if astParser != failing {
+		t.Errorf("got astParser %v after loading, want it restored", astParser)
+	}
+
+	if got, want := len(scenarios), 2; got != want {
+		t.Errorf("got %d scenarios, want %d", got, want)
+	}
+	if currentScenario != defaultScenario {
+		t.Errorf("got current scenario %s, want %s", currentScenario, defaultScenario)
+	}
+	if e, err := originalGraph.edge("a", "b"); err != nil || e.NumUsages != 3 {
+		t.Errorf("got edge (a, b) = %v, %v, want 3 usages", e, err)
+	}
+	if got, want := originalGraph.vertices["c"].SizeBytes, int64(100); got != want {
+		t.Errorf("got size of c %d, want %d", got, want)
+	}
+	if _, err := userGraph.edge("b", "c"); err == nil {
+		t.Error("expected (b, c) to still be cut")
+	}
+	if _, ok := shoppingCart.Edges["b"]["c"]; !ok {
+		t.Error("expected (b, c) to be in the shopping cart")
+	}
+
+	// The undone vertex cut can still be redone, and restoring the edge uses
+	// the saved usages.
+	if ok, err := opHistory.redo(userGraph, shoppingCart); err != nil || !ok {
+		t.Fatalf("redo: got %v, %v", ok, err)
+	}
+	if _, ok := shoppingCart.Vertices["d"]; !ok {
+		t.Error("expected d to be cut after redo")
+	}
+	if err := opHistory.do(operation{Kind: restoreEdge, From: "b", To: "c"}, userGraph, shoppingCart); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := userGraph.edge("b", "c"); err != nil || e.NumUsages != 7 {
+		t.Errorf("got restored edge (b, c) = %v, %v, want 7 usages", e, err)
+	}
+}
+
+func TestLoadSessionAnalyzesPending(t *testing.T) {
+	defer func() {
+		moduleSizer = &testModuleSizer{}
+		astParser = &testASTParser{}
+	}()
+
+	dir, err := ioutil.TempDir("", "lean-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// As if saved before the background analysis got anywhere.
+	originalGraph, err = parseGraph(bytes.NewBufferString("a b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenarios = map[string]*scenario{defaultScenario: newScenario(originalGraph)}
+	if err := saveSession(path); err != nil {
+		t.Fatal(err)
+	}
+
+	moduleSizer = mapModuleSizer{"a": 1, "b": 10}
+	astParser = mapASTParser{"a b": 3}
+	if err := loadSession(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range []*graph{originalGraph, userGraph} {
+		if v := g.vertices["b"]; v.SizeBytes != 10 || v.AnalysisStatus != "" {
+			t.Errorf("got b = %v, %q, want it sized at 10", v, v.AnalysisStatus)
+		}
+		if e, err := g.edge("a", "b"); err != nil || e.NumUsages != 3 || e.AnalysisStatus != "" {
+			t.Errorf("got edge (a, b) = %v, %v, want it analyzed with 3 usages", e, err)
+		}
+	}
+}
+
+func TestLoadSessionWrongVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lean-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+	if err := ioutil.WriteFile(path, []byte(`{"Version": 999}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := loadSession(path); err == nil {
+		t.Error("expected error loading a session with an unknown version, got nil")
+	}
+}
@@ -22,23 +22,167 @@ svg.call(zoom.transform, d3.zoomIdentity.translate(20, 0).scale(initialScale))
 
 svg.attr('height', g.graph().height * initialScale + 40)
 
+// In a report written by `lean report -html` there's no server: the page is
+// read-only, and its requests are answered from the snapshot embedded in it.
+const snapshot = window.leanSnapshot
+const readOnly = snapshot != undefined
+
+// snapshotFetch answers a request from the snapshot, as the server would.
+const snapshotFetch = (url, opts) => {
+  const u = new URL(url, window.location.href)
+  const view = u.searchParams.get('view') || 'raw'
+  const body = opts && opts.body ? JSON.parse(opts.body) : {}
+  const respond = (out, status) => Promise.resolve(new Response(JSON.stringify(out), {status: status || 200}))
+  const notFound = _ => respond(`${u.pathname} isn't in this report`, 404)
+
+  // The snapshot only keeps the labels of edges, which is all that's used.
+  const asEdge = (from, to) => ({From: {Label: from}, To: {Label: to}})
+  const asCut = c => {
+    if (c == undefined) {
+      return notFound()
+    }
+    const edges = {}
+    c.Edges.forEach(([from, to]) => {
+      edges[from] = edges[from] || {}
+      edges[from][to] = asEdge(from, to)
+    })
+    return respond({edges: edges, vertices: c.Vertices, modules: c.Modules})
+  }
+  const asPath = labels => labels.slice(1).map((to, i) => asEdge(labels[i], to))
+
+  switch (u.pathname) {
+    case '/graph':
+    case '/suggest':
+      return respond(snapshot.Responses[`${u.pathname}?view=${view}`])
+    case '/hypotheticalCut':
+      return asCut((snapshot.EdgeCuts[body.from] || {})[body.to])
+    case '/hypotheticalVertexCut':
+      return asCut(snapshot.VertexCuts[body.vertex])
+    case '/why':
+      const why = snapshot.Why[view][u.searchParams.get('module')]
+      if (why == undefined) {
+        return notFound()
+      }
+      return respond({Shortest: asPath(why.Shortest), Paths: why.Paths.map(asPath)})
+  }
+  if (snapshot.Responses[u.pathname] == undefined) {
+    return notFound()
+  }
+  return respond(snapshot.Responses[u.pathname])
+}
+
+if (readOnly) {
+  window.fetch = snapshotFetch
+  document.body.classList.add('readOnly')
+  document.getElementById('scenarioSelect').disabled = true
+  document.getElementById('removeVertex').innerHTML = 'Hover to preview removing module'
+}
+
 const bytesInMb = 1000000
+// Sizes and usages are -1 until they're known.
 const prettifySize = sizeBytes => {
-  if (sizeBytes == 0) {
+  if (sizeBytes <= 0) {
     return '?'
   }
   const sizeMb = Math.ceil(sizeBytes/bytesInMb)
   return `${sizeMb}mb`
 }
+
+// Whether every version of a module is collapsed into the selected one. The
+// collapsed view is read-only: cuts are made in the raw view.
+let collapsed = false
+const viewQuery = _ => collapsed ? 'view=collapsed' : 'view=raw'
+
+// Map of from => to => impact of cutting that edge, from /suggest.
+let impacts = {}
+
+// edgeSizeBytes is how many bytes cutting edge would free. Edges without a
+// known impact (for example, ones already cut) fall back to the size of 'to'.
+const edgeSizeBytes = edge => {
+  const from = edge.From.Label
+  const to = edge.To.Label
+  if (impacts[from] != undefined && impacts[from][to] != undefined) {
+    return impacts[from][to].SizeBytes
+  }
+  return edge.To.SizeBytes
+}
 const prettifyRatio = edge => {
-  if (edge.To.SizeBytes == 0 || edge.NumUsages == 0) {
+  if (edgeSizeBytes(edge) <= 0 || edge.NumUsages <= 0) {
     return '?'
   }
-  const sizeMb = Math.ceil(edge.To.SizeBytes/bytesInMb)
+  const sizeMb = Math.ceil(edgeSizeBytes(edge)/bytesInMb)
   const ratio = sizeMb / edge.NumUsages
   return ratio.toFixed(2)
 }
 
+// Vertices and edges whose analysis failed, or whose modules are missing in
+// offline mode, are of unknown size or usages, rather than not known yet.
+const analysisFailed = x => x.AnalysisStatus == 'failed' || x.AnalysisStatus == 'missing'
+const analysisMissing = x => x.AnalysisStatus == 'missing'
+
+const nodeLabel = vertex => {
+  const size = analysisFailed(vertex) ? 'unknown' : prettifySize(vertex.SizeBytes)
+  const retained = prettifySize(vertex.RetainedBytes)
+  const shared = prettifySize(vertex.SharedBytes)
+  let label = `${vertex.Label}\n${size} (retained ${retained} / ${vertex.RetainedVertices} modules, shared ${shared} / ${vertex.SharedVertices} modules)`
+  if (vertex.Module) {
+    label += `\nin module ${vertex.Module}`
+  }
+  if (vertex.Replace) {
+    label += `\nreplaced by ${vertex.Replace}`
+  }
+  if (vertex.Deprecated) {
+    label += `\ndeprecated: ${vertex.Deprecated}`
+  }
+  if (analysisMissing(vertex)) {
+    label += '\nmissing: not downloaded in offline mode'
+  } else if (analysisFailed(vertex)) {
+    label += `\ncouldn't size: ${vertex.AnalysisError}`
+  }
+  return label
+}
+
+// The diff being served, from /diff, or null if lean isn't serving a diff.
+let graphDiff = null
+
+// nodeClass distinguishes replaced and deprecated modules, as reported by
+// go list -m -json all, modules that couldn't be sized or are missing, and
+// modules added by the diff being served.
+const nodeClass = vertex => {
+  const classes = []
+  if (graphDiff != null && graphDiff.AddedVertices.includes(vertex.Label)) {
+    classes.push('added')
+  }
+  if (vertex.Replace) {
+    classes.push('replaced')
+  }
+  if (vertex.Deprecated) {
+    classes.push('deprecated')
+  }
+  if (analysisFailed(vertex)) {
+    classes.push('unknown')
+  }
+  if (analysisMissing(vertex)) {
+    classes.push('missing')
+  }
+  return classes.join(' ')
+}
+
+// edgeClass distinguishes edges that couldn't be analyzed, and edges added by
+// the diff being served.
+const edgeClass = edge => {
+  const from = edge.From.Label
+  const to = edge.To.Label
+  const classes = []
+  if (graphDiff != null && graphDiff.AddedEdges[from] != undefined && graphDiff.AddedEdges[from][to] != undefined) {
+    classes.push('added')
+  }
+  if (analysisFailed(edge)) {
+    classes.push('unknown')
+  }
+  return classes.join(' ')
+}
+
 const redrawGraph = graph => {
   // Remove initial node.
   g.removeNode('loading')
@@ -77,22 +221,12 @@ const redrawGraph = graph => {
     const from = entry[0]
     const tos = entry[1]
     for (const to in tos) {
-      const fromSize = prettifySize(tos[to].From.SizeBytes)
-      const toSize = prettifySize(tos[to].To.SizeBytes)
-
-      if (!g.hasNode(from) && !g.hasNode(to)) {
-        g.setNode(from, {label: `${from}\n${fromSize}`})
-        g.setNode(to, {label: `${to}\n${toSize}`})
-        g.setEdge(from, to, {})
-      } else if (!g.hasNode(from)) {
-        g.setNode(from, {label: `${from}\n${fromSize}`})
-        g.setEdge(from, to, {})
-      } else if (!g.hasNode(to)) {
-        g.setNode(to, {label: `${to}\n${toSize}`})
-        g.setEdge(from, to, {})
-      } else if (!g.hasEdge(from, to)) {
-        g.setEdge(from, to, {})
-      }
+      // Retained and shared sizes change as edges are cut, and analysis can
+      // fail after the graph is first drawn, so labels and classes are always
+      // refreshed.
+      g.setNode(from, {label: nodeLabel(tos[to].From), class: nodeClass(tos[to].From)})
+      g.setNode(to, {label: nodeLabel(tos[to].To), class: nodeClass(tos[to].To)})
+      g.setEdge(from, to, {class: edgeClass(tos[to])})
     }
   })
 
@@ -108,6 +242,38 @@ const redrawGraph = graph => {
     .on('mouseout', function(e) { // Must be a func to have correct 'this' scope.
       focusOutEdge(e.v, e.w)
     })
+
+  // Add clicks.
+  d3.select('svg')
+    .selectAll('g.node')
+    .on('click', function(v) { // Must be a func to have correct 'this' scope.
+      selectVertex(v)
+      highlightWhy(v)
+    })
+}
+
+// highlightWhy colours the paths by which the root depends on module, with
+// the shortest path in bold.
+const highlightWhy = module => {
+  fetch(`/why?module=${encodeURIComponent(module)}&${viewQuery()}`).then(resp => {
+    resp.json().then(ex => {
+      focusOutEdge()
+
+      const colourEdge = (from, to, width) => {
+        d3.select('svg')
+          .selectAll('path')
+          .filter(svgE => svgE.v == from && svgE.w == to)
+          .each(function() {
+            d3.select(this).style('stroke', 'blue')
+            d3.select(this).style('stroke-width', width)
+          })
+      }
+      ex.Paths.forEach(path => {
+        path.forEach(e => colourEdge(e.From.Label, e.To.Label, '3px'))
+      })
+      ex.Shortest.forEach(e => colourEdge(e.From.Label, e.To.Label, '5px'))
+    })
+  }).catch(err => console.error(err))
 }
 
 const drawList = (id, entries, clickMethod) => {
@@ -139,15 +305,16 @@ const drawList = (id, entries, clickMethod) => {
       if (e1ratio == '?') return 1
       if (e2ratio == '?') return -1
 
-      if (e1ratio < e2ratio) return 1
-      if (e1ratio > e2ratio) return -1
-      return 0
+      return parseFloat(e2ratio) - parseFloat(e1ratio)
     })
     .forEach(edge => { // Print to page.
       const from = edge.From.Label
       const to = edge.To.Label
-      const toSize = prettifySize(edge.To.SizeBytes)
-      const toPackageUsages = edge.NumUsages
+      const toSize = prettifySize(edgeSizeBytes(edge))
+      let toPackageUsages = edge.NumUsages < 0 ? '?' : edge.NumUsages
+      if (analysisFailed(edge)) {
+        toPackageUsages = 'unknown'
+      }
       const ratio = prettifyRatio(edge)
 
       // Create a new list item.
@@ -163,27 +330,33 @@ const drawList = (id, entries, clickMethod) => {
       const sizeText = document.createElement('div')
       sizeText.innerHTML = `${toSize} / ${toPackageUsages} = ${ratio}`
       sizeText.className = 'ratio'
+      if (analysisFailed(edge)) {
+        sizeText.className += ' unknown'
+        sizeText.title = `couldn't analyze: ${edge.AnalysisError}`
+      }
       newEdgeRow.appendChild(sizeText)
   
-      // Add button.
-      const rowButton = document.createElement('button')
-      rowButton.type = 'button'
-      if (clickMethod == 'POST') {
-        rowButton.innerHTML = 'Return'
-      } else {
-        rowButton.innerHTML = 'Remove'
-      }
-      rowButton.className = 'right'
-      rowButton.onclick = _ => {
-        fetch('/edge', {method: clickMethod, body: JSON.stringify({'from': from, 'to': to})}).then(resp => {
-          resp.json().then(both => {
-            redrawGraph(both['graph'])
-            redrawEdgelist(both['graph'])
-            redrawShoppingCart(both['shoppingCart'])
-          })
-        }).catch(err => console.error(err))
+      // Add button. Collapsed edges aren't real edges, so they can't be cut,
+      // and nothing can be cut in a read-only report.
+      if (!readOnly && (!collapsed || clickMethod == 'POST')) {
+        const rowButton = document.createElement('button')
+        rowButton.type = 'button'
+        if (clickMethod == 'POST') {
+          rowButton.innerHTML = 'Return'
+        } else {
+          rowButton.innerHTML = 'Remove'
+        }
+        rowButton.className = 'right'
+        rowButton.onclick = _ => {
+          fetch('/edge', {method: clickMethod, body: JSON.stringify({'from': from, 'to': to})}).then(resp => {
+            resp.json().then(both => {
+              redrawView(both['graph'])
+              redrawShoppingCart(both['shoppingCart'])
+            })
+          }).catch(err => console.error(err))
+        }
+        newEdgeRow.appendChild(rowButton)
       }
-      newEdgeRow.appendChild(rowButton)
   
       // Give the list item properties.
       newEdgeRow.id = `${id}-${from}${to}`
@@ -212,50 +385,72 @@ const focusInEdge = (from, to) => {
       d3.select(this).style('stroke-width', '5px')
     })
 
+  if (collapsed) {
+    return
+  }
   fetch('/hypotheticalCut', {method: 'POST', body: JSON.stringify({'from': from, 'to': to})}).then(resp => {
     resp.json().then(respj => {
-      const cutEdges = respj['edges']
-      const cutVertices = respj['vertices']
-
-      Object.entries(cutEdges).forEach(entry => {
-        const from = entry[0]
-        const tos = entry[1]
-        for (const to in tos) {
-          // Colour edgerow in list below.
-          document.getElementById(`edgeList-${from}${to}`).style.backgroundColor = 'red'
-
-          // Colour edge.
-          d3.select('svg')
-            .selectAll('path')
-            .filter(svgE => svgE.v == from && svgE.w == to)
-            .each(function() {
-              d3.select(this).style('stroke', 'red')
-            })
-        }
-      })
+      colourCut(respj['edges'], respj['vertices'])
+      showPrunedModules(respj['modules'])
+    })
+  })
+}
 
-      // Colour vertex.
-      Object.entries(cutVertices).forEach(varr => {
-        const v = varr[1]
-        d3.select('svg')
-          .selectAll('tspan')
-          .filter(spanText => spanText == v)
-          .each(function() {
-            const tspan = this
-            d3.select(tspan).style('stroke', 'red')
-            const text = tspan.parentNode
-            const g1 = text.parentNode
-            const g2 = g1.parentNode
-            const g3 = g2.parentNode
-            const rect = d3.select(g3).select('rect')
-            rect.style('stroke', 'red')
-          })
+// showPrunedModules lists the modules that a hypothetical cut in a package
+// graph removes entirely.
+const showPrunedModules = modules => {
+  const el = document.getElementById('prunedModules')
+  if (modules == undefined || modules.length == 0) {
+    el.innerHTML = ''
+    return
+  }
+  el.innerHTML = `Removes modules: ${modules.join(', ')}`
+}
+
+// colourCut colours the edges and vertices that a hypothetical cut prunes.
+const colourCut = (cutEdges, cutVertices) => {
+  Object.entries(cutEdges).forEach(entry => {
+    const from = entry[0]
+    const tos = entry[1]
+    for (const to in tos) {
+      // Colour edgerow in list below.
+      const row = document.getElementById(`edgeList-${from}${to}`)
+      if (row != null) {
+        row.style.backgroundColor = 'red'
+      }
+
+      // Colour edge.
+      d3.select('svg')
+        .selectAll('path')
+        .filter(svgE => svgE.v == from && svgE.w == to)
+        .each(function() {
+          d3.select(this).style('stroke', 'red')
+        })
+    }
+  })
+
+  // Colour vertex.
+  Object.entries(cutVertices).forEach(varr => {
+    const v = varr[1]
+    d3.select('svg')
+      .selectAll('tspan')
+      .filter(spanText => spanText == v)
+      .each(function() {
+        const tspan = this
+        d3.select(tspan).style('stroke', 'red')
+        const text = tspan.parentNode
+        const g1 = text.parentNode
+        const g2 = g1.parentNode
+        const g3 = g2.parentNode
+        const rect = d3.select(g3).select('rect')
+        rect.style('stroke', 'red')
       })
-    })
   })
 }
 
 const focusOutEdge = _ => {
+  showPrunedModules([])
+
   // Reset edgerow in list below.
   Array.from(document.getElementsByClassName('edgeRow')).forEach(e => {
     e.style.backgroundColor = 'transparent'
@@ -266,7 +461,7 @@ const focusOutEdge = _ => {
   d3.select('svg')
     .selectAll('rect')
     .each(function() {
-      d3.select(this).style('stroke', 'black')
+      d3.select(this).style('stroke', null)
     })
   d3.select('svg')
     .selectAll('tspan')
@@ -274,38 +469,452 @@ const focusOutEdge = _ => {
       d3.select(this).style('stroke', 'black')
     })
 
-  // Reset edges.
+  // Reset edges, back to whatever their class styles them as.
   d3.select('svg')
     .selectAll('path')
     .each(function() {
-      d3.select(this).style('stroke', 'black')
-      d3.select(this).style('stroke-width', '1.5px')
+      d3.select(this).style('stroke', null)
+      d3.select(this).style('stroke-width', null)
     })
 }
 
 const redrawEdgelist = graph => {
-  drawList('edgeList', graph, 'DELETE')
+  // Fetch the impact of cutting every edge first, so that the list is sorted
+  // by everything a cut drags along with it.
+  fetch(`/suggest?${viewQuery()}`).then(resp => {
+    resp.json().then(suggestions => {
+      impacts = {}
+      suggestions.forEach(s => {
+        const from = s.Edge.From.Label
+        const to = s.Edge.To.Label
+        if (impacts[from] == undefined) {
+          impacts[from] = {}
+        }
+        impacts[from][to] = s.Impact
+      })
+      drawList('edgeList', graph, 'DELETE')
+    })
+  }).catch(err => console.error(err))
 }
 
 const redrawShoppingCart = shoppingCart => {
-  drawList('shoppingCart', shoppingCart, 'POST')
+  drawList('shoppingCart', shoppingCart['Edges'], 'POST')
+
+  // Vertices cut entirely are one entry each, however many edges went with
+  // them.
+  const el = document.getElementById('shoppingCart')
+  Object.entries(shoppingCart['Vertices']).forEach(entry => {
+    const vertex = entry[0]
+    const numEdges = Object.keys(entry[1]).length
+
+    const newVertexRow = document.createElement('div')
+    newVertexRow.className = 'edgeRow'
+
+    const rowText = document.createElement('div')
+    rowText.innerHTML = `${vertex} (module, ${numEdges} edges)`
+    rowText.className = 'edge'
+    newVertexRow.appendChild(rowText)
+
+    if (!readOnly) {
+      const rowButton = document.createElement('button')
+      rowButton.type = 'button'
+      rowButton.innerHTML = 'Return'
+      rowButton.className = 'right'
+      rowButton.onclick = _ => cutVertex(vertex, 'POST')
+      newVertexRow.appendChild(rowButton)
+    }
+
+    el.appendChild(newVertexRow)
+  })
+
+  // Whenever the cuts change, so does what the current scenario removes, and
+  // which versions are selected.
+  refreshScenarios()
+  refreshVersionChanges()
+}
+
+// refreshVersionChanges lists the modules whose selected version the current
+// scenario's cuts change.
+const refreshVersionChanges = _ => {
+  fetch('/buildList').then(resp => {
+    resp.json().then(out => {
+      const table = document.getElementById('versionChanges')
+      table.innerHTML = `<tr><th>Module</th><th>Before</th><th>After (${out['buildList'].length} modules selected)</th></tr>`
+      out['changes'].forEach(c => {
+        const row = document.createElement('tr')
+        if (c.Downgrade) {
+          row.className = 'downgrade'
+        }
+        row.innerHTML = `<td>${c.Path}</td><td>${c.Before}</td><td>${c.After || 'removed'}</td>`
+        table.appendChild(row)
+      })
+    })
+  }).catch(err => console.error(err))
+}
+
+// redrawScenarios redraws the scenario picker, and the side-by-side summary of
+// what each scenario removes.
+const redrawScenarios = summaries => {
+  const select = document.getElementById('scenarioSelect')
+  select.innerHTML = ''
+  const table = document.getElementById('scenarioSummary')
+  table.innerHTML = '<tr><th>Scenario</th><th>Size removed</th><th>Modules removed</th><th>Edges removed</th></tr>'
+
+  summaries.forEach(s => {
+    const option = document.createElement('option')
+    option.value = s.Name
+    option.innerHTML = s.Name
+    option.selected = s.Current
+    select.appendChild(option)
+
+    const row = document.createElement('tr')
+    if (s.Current) {
+      row.className = 'current'
+    }
+    row.innerHTML = `<td>${s.Name}</td><td>${prettifySize(s.Removed.SizeBytes)}</td><td>${s.Removed.NumVertices}</td><td>${s.Removed.NumEdges}</td>`
+    table.appendChild(row)
+  })
+}
+
+const refreshScenarios = _ => {
+  fetch('/scenarios').then(resp => {
+    resp.json().then(summaries => redrawScenarios(summaries))
+  }).catch(err => console.error(err))
+}
+
+// scenarioRequest calls one of the scenario endpoints, and redraws everything
+// with the result.
+const scenarioRequest = (path, body) => {
+  fetch(path, {method: 'POST', body: JSON.stringify(body)}).then(resp => {
+    if (!resp.ok) {
+      resp.text().then(text => alert(text))
+      return
+    }
+    resp.json().then(all => {
+      focusOutEdge()
+      redrawView(all['graph'])
+      redrawShoppingCart(all['shoppingCart'])
+      redrawScenarios(all['scenarios'])
+    })
+  }).catch(err => console.error(err))
+}
+
+document.getElementById('scenarioSelect').onchange = e => {
+  scenarioRequest('/switchScenario', {'name': e.target.value})
+}
+document.getElementById('newScenario').onclick = _ => {
+  const name = prompt('Name of the new scenario')
+  if (name) {
+    scenarioRequest('/createScenario', {'name': name})
+  }
+}
+document.getElementById('cloneScenario').onclick = _ => {
+  const current = document.getElementById('scenarioSelect').value
+  const name = prompt(`Name of the copy of ${current}`)
+  if (name) {
+    scenarioRequest('/createScenario', {'name': name, 'clone': current})
+  }
+}
+document.getElementById('deleteScenario').onclick = _ => {
+  const current = document.getElementById('scenarioSelect').value
+  if (confirm(`Delete scenario ${current}?`)) {
+    scenarioRequest('/deleteScenario', {'name': current})
+  }
+}
+
+// cutVertex removes (DELETE) or returns (POST) every edge into vertex.
+const cutVertex = (vertex, method) => {
+  fetch('/vertex', {method: method, body: JSON.stringify({'vertex': vertex})}).then(resp => {
+    resp.json().then(both => {
+      focusOutEdge()
+      redrawView(both['graph'])
+      redrawShoppingCart(both['shoppingCart'])
+    })
+  }).catch(err => console.error(err))
+}
+
+// selectVertex shows the controls for the clicked vertex.
+const selectVertex = vertex => {
+  document.getElementById('selectedVertex').innerHTML = vertex
+
+  const button = document.getElementById('removeVertex')
+  button.disabled = collapsed
+  button.onclick = _ => {
+    if (!readOnly) {
+      cutVertex(vertex, 'DELETE')
+    }
+  }
+  button.onmouseover = _ => {
+    fetch('/hypotheticalVertexCut', {method: 'POST', body: JSON.stringify({'vertex': vertex})}).then(resp => {
+      resp.json().then(respj => {
+        colourCut(respj['edges'], respj['vertices'])
+        showPrunedModules(respj['modules'])
+      })
+    }).catch(err => console.error(err))
+  }
+  button.onmouseout = _ => focusOutEdge()
 }
 
 document.getElementById('reset').onclick = _ => {
   fetch('/reset').then(resp => {
     resp.json().then(both => {
-      redrawGraph(both['graph'])
-      redrawEdgelist(both['graph'])
+      redrawView(both['graph'])
+      redrawShoppingCart(both['shoppingCart'])
+    })
+  }).catch(err => console.error(err))
+}
+
+// step calls /undo or /redo, and redraws with the result.
+const step = path => {
+  fetch(path, {method: 'POST'}).then(resp => {
+    resp.json().then(both => {
+      focusOutEdge()
+      redrawView(both['graph'])
       redrawShoppingCart(both['shoppingCart'])
     })
   }).catch(err => console.error(err))
 }
 
-fetch('/graph').then(resp => {
-  resp.json().then(graph => {
+document.getElementById('save').onclick = _ => {
+  fetch('/save', {method: 'POST'}).then(resp => {
+    if (!resp.ok) {
+      resp.text().then(text => alert(text))
+      return
+    }
+    resp.json().then(out => alert(`Saved session to ${out['path']}`))
+  }).catch(err => console.error(err))
+}
+
+// The export link downloads the current scenario's graph, with its cuts
+// marked, in the selected format.
+const exportExtensions = {dot: 'dot', graphml: 'graphml', mermaid: 'mmd', json: 'json'}
+const updateExportLink = _ => {
+  const format = document.getElementById('exportFormat').value
+  const link = document.getElementById('export')
+  link.href = `/export?format=${format}&cuts=true`
+  link.download = `lean.${exportExtensions[format]}`
+}
+document.getElementById('exportFormat').onchange = updateExportLink
+updateExportLink()
+
+document.getElementById('undo').onclick = _ => step('/undo')
+document.getElementById('redo').onclick = _ => step('/redo')
+
+// Ctrl+Z (or Cmd+Z) undoes, and Ctrl+Shift+Z or Ctrl+Y redoes.
+document.addEventListener('keydown', e => {
+  if (readOnly || (!e.ctrlKey && !e.metaKey)) {
+    return
+  }
+  const key = e.key.toLowerCase()
+  if (key == 'z' && !e.shiftKey) {
+    e.preventDefault()
+    step('/undo')
+  } else if ((key == 'z' && e.shiftKey) || key == 'y') {
+    e.preventDefault()
+    step('/redo')
+  }
+})
+
+// redrawView redraws the graph and the edge list. In the collapsed view, the
+// raw graph that the server responded with is swapped for the collapsed one.
+const redrawView = graph => {
+  if (!collapsed) {
     redrawGraph(graph)
     redrawEdgelist(graph)
+    return
+  }
+  fetch(`/graph?${viewQuery()}`).then(resp => {
+    resp.json().then(collapsedGraph => {
+      redrawGraph(collapsedGraph)
+      redrawEdgelist(collapsedGraph)
+    })
+  }).catch(err => console.error(err))
+}
+
+document.getElementById('collapse').onclick = e => {
+  collapsed = !collapsed
+  e.target.innerHTML = collapsed ? 'Show all versions' : 'Collapse versions'
+  document.getElementById('removeVertex').disabled = true
+  focusOutEdge()
+  fetch(`/graph?${viewQuery()}`).then(resp => {
+    resp.json().then(graph => {
+      redrawGraph(graph)
+      redrawEdgelist(graph)
+    })
+  }).catch(err => console.error(err))
+}
+
+// showDiff summarizes the diff being served: the totals before and after,
+// and what it removed or changed, since those aren't in the graph.
+const showDiff = d => {
+  const el = document.getElementById('diffSummary')
+  el.style.display = 'block'
+
+  const delta = (before, after, pretty) => {
+    const sign = after >= before ? '+' : '-'
+    return `${pretty(before)} -> ${pretty(after)} (${sign}${pretty(Math.abs(after - before))})`
+  }
+  const count = n => `${n}`
+  let html = `<h3>Diff</h3>
+    <div>Modules: ${delta(d.Before.NumVertices, d.After.NumVertices, count)}</div>
+    <div>Edges: ${delta(d.Before.NumEdges, d.After.NumEdges, count)}</div>
+    <div>Size: ${delta(d.Before.SizeBytes, d.After.SizeBytes, prettifySize)}</div>
+    <div>Added modules (highlighted): ${d.AddedVertices.length}</div>
+    <div>Removed modules: ${d.RemovedVertices.join(', ') || 'none'}</div>`
+  if (d.VersionChanges.length > 0) {
+    html += '<table><tr><th>Module</th><th>Before</th><th>After</th></tr>'
+    d.VersionChanges.forEach(c => {
+      html += `<tr><td>${c.Path}</td><td>${c.Before || 'none'}</td><td>${c.After || 'none'}</td></tr>`
+    })
+    html += '</table>'
+  }
+  el.innerHTML = html
+}
+
+// drawHistory charts how the graph grew over the module's git history. The
+// metric select picks what's charted, and hovering over a commit shows it.
+const drawHistory = h => {
+  const el = document.getElementById('history')
+  el.style.display = 'block'
+  const points = h.Points.filter(p => !p.Error)
+  const metrics = {
+    'Modules': p => p.NumVertices,
+    'Edges': p => p.NumEdges,
+    'Size (mb)': p => p.SizeBytes / bytesInMb,
+  }
+
+  const select = document.getElementById('historyMetric')
+  if (select.options.length == 0) {
+    Object.keys(metrics).forEach(m => {
+      const option = document.createElement('option')
+      option.value = m
+      option.innerHTML = m
+      select.appendChild(option)
+    })
+    select.onchange = _ => drawHistory(h)
+  }
+  const metric = metrics[select.value]
+
+  const width = 600, height = 150, margin = 40
+  const chart = d3.select('#historyChart')
+  chart.selectAll('*').remove()
+  chart.attr('width', width + 2 * margin).attr('height', height + 2 * margin)
+  const inner = chart.append('g').attr('transform', `translate(${margin},${margin / 2})`)
+
+  const x = d3.scaleTime()
+    .domain(d3.extent(points, p => new Date(p.Time)))
+    .range([0, width])
+  const y = d3.scaleLinear()
+    .domain([0, d3.max(points, metric) || 1])
+    .range([height, 0])
+  inner.append('g').attr('transform', `translate(0,${height})`).call(d3.axisBottom(x).ticks(6))
+  inner.append('g').call(d3.axisLeft(y).ticks(5))
+  inner.append('path')
+    .datum(points)
+    .attr('class', 'historyLine')
+    .attr('d', d3.line().x(p => x(new Date(p.Time))).y(p => y(metric(p))))
+
+  const introduced = {}
+  h.Introduced.forEach(m => {
+    introduced[m.Commit] = (introduced[m.Commit] || 0) + 1
   })
+  inner.selectAll('circle')
+    .data(points)
+    .enter()
+    .append('circle')
+    .attr('class', 'historyPoint')
+    .attr('r', 3)
+    .attr('cx', p => x(new Date(p.Time)))
+    .attr('cy', p => y(metric(p)))
+    .append('title')
+    .text(p => `${p.Commit.slice(0, 12)} ${p.Subject}\n${p.NumVertices} modules, ${p.NumEdges} edges, ${prettifySize(p.SizeBytes)}\nintroduced ${introduced[p.Commit] || 0} modules`)
+}
+
+// While lean sizes modules and analyzes edges in the background, /events
+// streams each result as it's found. The graph is redrawn with them every so
+// often, rather than on every one.
+let refreshTimer = null
+const refresh = _ => {
+  fetch(`/graph?${viewQuery()}`).then(resp => {
+    resp.json().then(graph => {
+      redrawGraph(graph)
+      redrawEdgelist(graph)
+    })
+  }).catch(err => console.error(err))
+  fetch('/shoppingCart').then(resp => {
+    resp.json().then(shoppingCart => redrawShoppingCart(shoppingCart))
+  }).catch(err => console.error(err))
+}
+const scheduleRefresh = _ => {
+  if (refreshTimer == null) {
+    refreshTimer = setTimeout(_ => {
+      refreshTimer = null
+      refresh()
+    }, 2000)
+  }
+}
+
+const showAnalysisProgress = p => {
+  const el = document.getElementById('analysisProgress')
+  const problems = []
+  if (p.Failed) {
+    problems.push(`${p.Failed} failed`)
+  }
+  if (p.Missing) {
+    problems.push(`${p.Missing} modules missing offline`)
+  }
+  if (p.Done) {
+    el.innerHTML = problems.length ? `Analysis done: ${problems.join(', ')}. Their sizes or usages are marked unknown` : ''
+    return
+  }
+  const failed = problems.map(s => `, ${s}`).join('')
+  el.innerHTML = `Analyzing: ${p.SizedVertices}/${p.NumVertices} modules sized, ${p.AnalyzedEdges}/${p.NumEdges} edges analyzed${failed}`
+}
+
+if (!readOnly) {
+  // Whether any results have come in since the graph was first drawn.
+  let analyzing = false
+  const events = new EventSource('/events')
+  events.addEventListener('progress', e => {
+    const p = JSON.parse(e.data).Progress
+    analyzing = !p.Done
+    showAnalysisProgress(p)
+  })
+  const onResult = e => {
+    showAnalysisProgress(JSON.parse(e.data).Progress)
+    scheduleRefresh()
+  }
+  events.addEventListener('size', onResult)
+  events.addEventListener('usages', onResult)
+  events.addEventListener('done', e => {
+    // Otherwise, the browser would reconnect once the stream ends.
+    events.close()
+    showAnalysisProgress(JSON.parse(e.data).Progress)
+    if (analyzing) {
+      clearTimeout(refreshTimer)
+      refreshTimer = null
+      refresh()
+    }
+  })
+}
+
+fetch('/history').then(resp => {
+  if (resp.ok) {
+    resp.json().then(h => drawHistory(h))
+  }
+}).catch(err => console.error(err))
+
+// The diff, if any, is fetched first, so that the graph is drawn with what it
+// added highlighted.
+fetch('/diff').then(resp => {
+  if (resp.ok) {
+    return resp.json().then(d => {
+      graphDiff = d
+      showDiff(d)
+    })
+  }
+}).then(_ => fetch('/graph')).then(resp => {
+  resp.json().then(graph => redrawView(graph))
 }).catch(err => console.error(err))
 
 fetch('/shoppingCart').then(resp => {
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sort"
+)
+
+// suggestion is a candidate edge to cut, and what cutting it would prune.
+type suggestion struct {
+	Edge   *edge
+	Impact cutImpact
+
+	// Score is the bytes pruned per unit of cutCost. Higher is better: a
+	// cheap cut that prunes a lot. It's 0 for edges whose usages are unknown,
+	// since there's no telling how cheap they are.
+	Score float64
+}
+
+// suggestCuts ranks every edge reachable from the root by how much cutting it
+// alone would prune against how much it's used, and returns the best n. Edges
+// whose usages are unknown, because they haven't been or couldn't be
+// analyzed, are ranked last. If n is not positive, every edge is returned.
+func (g *graph) suggestCuts(n int) []suggestion {
+	impacts := g.edgeImpacts()
+
+	g.mu.Lock()
+	out := []suggestion{}
+	for from, tos := range impacts {
+		for to, impact := range tos {
+			e := (*g.edges)[from][to]
+			s := suggestion{Edge: e, Impact: impact}
+			if !usagesUnknown(e.NumUsages) {
+				s.Score = float64(impact.SizeBytes) / float64(cutCost(e))
+			}
+			out = append(out, s)
+		}
+	}
+	g.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if aKnown, bKnown := !usagesUnknown(a.Edge.NumUsages), !usagesUnknown(b.Edge.NumUsages); aKnown != bKnown {
+			return aKnown
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Impact.NumVertices != b.Impact.NumVertices {
+			return a.Impact.NumVertices > b.Impact.NumVertices
+		}
+		if a.Edge.From.Label != b.Edge.From.Label {
+			return a.Edge.From.Label < b.Edge.From.Label
+		}
+		return a.Edge.To.Label < b.Edge.To.Label
+	})
+	if n > 0 && n < len(out) {
+		out = out[:n]
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSuggestCuts(t *testing.T) {
+	moduleSizer = mapModuleSizer{"b": 100, "c": 1000, "d": 10, "e": 1}
+	astParser = mapASTParser{"a b": 0, "a c": 9, "b d": 0, "c d": 0, "c e": 4}
+	defer func() {
+		moduleSizer = &testModuleSizer{}
+		astParser = &testASTParser{}
+	}()
+
+	g, err := newGraph(bytes.NewBufferString("a b\na c\nb d\nc d\nc e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, s := range g.suggestCuts(3) {
+		got = append(got, s.Edge.From.Label+" "+s.Edge.To.Label)
+	}
+	// a c prunes c and e (1001 bytes) for a cost of 10. a b prunes only b (100
+	// bytes), since d is still reachable through c, for a cost of 1.
+	want := []string{"a c", "a b", "c e"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got different suggestions (extraneous -, missing +):\n%s", diff)
+	}
+
+	if got, want := len(g.suggestCuts(0)), 5; got != want {
+		t.Errorf("got %d suggestions with no limit, want %d", got, want)
+	}
+
+	// An edge whose usages are unknown isn't a free cut: it's ranked last,
+	// with no score.
+	(*g.edges)["a"]["c"].NumUsages = -1
+	all := g.suggestCuts(0)
+	if last := all[len(all)-1]; last.Edge.From.Label != "a" || last.Edge.To.Label != "c" || last.Score != 0 {
+		t.Errorf("got last suggestion %v with score %v, want a -> c with score 0", last.Edge, last.Score)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// defaultMaxPaths is how many paths why returns if not told otherwise.
+const defaultMaxPaths = 100
+
+// explanation explains why a module is in the graph: the paths by which the
+// root depends on it.
+type explanation struct {
+	Module string
+
+	// Shortest is a shortest path from the root to the module. Each hop is
+	// an edge, annotated with its NumUsages.
+	Shortest []*edge
+
+	// Paths are the simple paths from the root to the module, sorted by
+	// length. There are at most maxPaths of them.
+	Paths [][]*edge
+
+	// Truncated is whether there were more than maxPaths paths.
+	Truncated bool
+}
+
+// why explains why module is reachable from the root. module may be a vertex
+// label, or a module path to match every version of the module.
+//
+// At most maxPaths simple paths are returned, or all of them if maxPaths is
+// not positive.
+func (g *graph) why(module string, maxPaths int) (*explanation, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	targets := make(map[string]struct{})
+	for _, t := range g.matchingVerticesLocked(module) {
+		targets[t] = struct{}{}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("vertex %s does not exist", module)
+	}
+
+	ex := &explanation{Module: module, Shortest: []*edge{}, Paths: [][]*edge{}}
+	if _, ok := targets[g.root]; ok {
+		// The root is in the graph because it's the root.
+		return ex, nil
+	}
+
+	// Find how far each vertex is from a target, so that the path searches
+	// don't wander down branches that lead nowhere.
+	reverse := make(map[string][]string)
+	for from, tos := range *g.edges {
+		for to := range tos {
+			reverse[to] = append(reverse[to], from)
+		}
+	}
+	dist := make(map[string]int)
+	var queue []string
+	for t := range targets {
+		dist[t] = 0
+		queue = append(queue, t)
+	}
+	for len(queue) > 0 {
+		to := queue[0]
+		queue = queue[1:]
+		for _, from := range reverse[to] {
+			if _, ok := dist[from]; ok {
+				continue
+			}
+			dist[from] = dist[to] + 1
+			queue = append(queue, from)
+		}
+	}
+	if _, ok := dist[g.root]; !ok {
+		return nil, fmt.Errorf("%s is not reachable from %s", module, g.root)
+	}
+
+	next := func(from string) []*edge {
+		var out []*edge
+		for to, e := range (*g.edges)[from] {
+			if _, ok := dist[to]; ok {
+				out = append(out, e)
+			}
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].To.Label < out[j].To.Label })
+		return out
+	}
+
+	// Follow the distances down for the shortest path.
+	for v := g.root; dist[v] > 0; {
+		for _, e := range next(v) {
+			if dist[e.To.Label] == dist[v]-1 {
+				ex.Shortest = append(ex.Shortest, e)
+				v = e.To.Label
+				break
+			}
+		}
+	}
+
+	// Depth-first search for every simple path, one length at a time so that
+	// if there are more than maxPaths, the shortest ones are kept.
+	onPath := map[string]struct{}{g.root: {}}
+	var path []*edge
+	var deeper bool // Whether any path was cut short by the length limit.
+	var dfs func(from string, length int)
+	dfs = func(from string, length int) {
+		for _, e := range next(from) {
+			if ex.Truncated {
+				return
+			}
+			to := e.To.Label
+			if _, ok := onPath[to]; ok {
+				continue
+			}
+			if len(path)+1+dist[to] > length {
+				deeper = true
+				continue
+			}
+			path = append(path, e)
+			_, isTarget := targets[to]
+			switch {
+			case isTarget && len(path) == length:
+				if maxPaths > 0 && len(ex.Paths) == maxPaths {
+					ex.Truncated = true
+				} else {
+					ex.Paths = append(ex.Paths, append([]*edge(nil), path...))
+				}
+			case !isTarget:
+				onPath[to] = struct{}{}
+				dfs(to, length)
+				delete(onPath, to)
+			}
+			path = path[:len(path)-1]
+		}
+	}
+	for length := dist[g.root]; !ex.Truncated; length++ {
+		deeper = false
+		dfs(g.root, length)
+		if !deeper {
+			break
+		}
+	}
+
+	return ex, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWhy(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	pathStrings := func(paths [][]*edge) []string {
+		var out []string
+		for _, p := range paths {
+			labels := []string{p[0].From.Label}
+			for _, e := range p {
+				labels = append(labels, e.To.Label)
+			}
+			out = append(out, strings.Join(labels, " "))
+		}
+		return out
+	}
+
+	for _, tc := range []struct {
+		desc          string
+		in            string
+		module        string
+		maxPaths      int
+		wantShortest  string
+		wantPaths     []string
+		wantTruncated bool
+	}{
+		{
+			desc:         "single path",
+			in:           "a b\nb c",
+			module:       "c",
+			wantShortest: "a b c",
+			wantPaths:    []string{"a b c"},
+		},
+		{
+			desc:         "several paths, shortest first",
+			in:           "a b\nb c\nc d\na d\nb d",
+			module:       "d",
+			wantShortest: "a d",
+			wantPaths:    []string{"a d", "a b d", "a b c d"},
+		},
+		{
+			desc:          "capped",
+			in:            "a b\nb c\nc d\na d\nb d",
+			module:        "d",
+			maxPaths:      2,
+			wantShortest:  "a d",
+			wantPaths:     []string{"a d", "a b d"},
+			wantTruncated: true,
+		},
+		{
+			desc:         "cycles are not followed",
+			in:           "a b\nb c\nc b\nc d",
+			module:       "d",
+			wantShortest: "a b c d",
+			wantPaths:    []string{"a b c d"},
+		},
+		{
+			desc:         "module path matches any version",
+			in:           "a b@v1\na c\nc b@v2",
+			module:       "b",
+			wantShortest: "a b@v1",
+			wantPaths:    []string{"a b@v1", "a c b@v2"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			g, err := newGraph(bytes.NewBufferString(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ex, err := g.why(tc.module, tc.maxPaths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pathStrings([][]*edge{ex.Shortest}); got[0] != tc.wantShortest {
+				t.Errorf("got shortest path %q, want %q", got[0], tc.wantShortest)
+			}
+			if diff := cmp.Diff(pathStrings(ex.Paths), tc.wantPaths); diff != "" {
+				t.Errorf("got different paths (extraneous -, missing +):\n%s", diff)
+			}
+			if ex.Truncated != tc.wantTruncated {
+				t.Errorf("got truncated %v, want %v", ex.Truncated, tc.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestWhyUnreachable(t *testing.T) {
+	moduleSizer = &testModuleSizer{}
+	astParser = &testASTParser{}
+
+	g, err := newGraph(bytes.NewBufferString("a b\nc d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.why("d", 0); err == nil {
+		t.Error("expected error for unreachable module, got nil")
+	}
+}